@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CacheFlushResponse is the response body for the cache flush endpoint
+type CacheFlushResponse struct {
+	Evicted int `json:"evicted"`
+}
+
+// MetricsResetResponse is the response body for the metrics reset endpoint,
+// holding each counter's value before the reset
+type MetricsResetResponse struct {
+	Previous map[string]int64 `json:"previous"`
+}
+
+// HealthDegradeResponse is the response body for the health degrade endpoint
+type HealthDegradeResponse struct {
+	Degraded bool `json:"degraded"`
+}
+
+// authorizeAdmin checks the request's bearer token against ADMIN_TOKEN,
+// writing an error response and returning false when it does not match.
+// Admin endpoints are disabled while ADMIN_TOKEN is unset.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		RespondWithError(w, r, "Admin endpoints are disabled", http.StatusForbidden)
+		return false
+	}
+
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		RespondWithError(w, r, "Invalid or missing admin token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// AdminCacheFlush empties the response caches so the next requests are
+// fetched fresh from the upstreams
+func AdminCacheFlush(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request to flush caches")
+
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	evicted := userCache.flush()
+	RespondWithJSON(w, CacheFlushResponse{Evicted: evicted}, http.StatusOK)
+
+	log.Printf("Successfully flushed %d cache entries", evicted)
+}
+
+// AdminMetricsReset zeroes the /metrics counters
+func AdminMetricsReset(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request to reset metrics")
+
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	previous := ResetMetrics()
+	RespondWithJSON(w, MetricsResetResponse{Previous: previous}, http.StatusOK)
+
+	log.Println("Successfully reset metrics")
+}
+
+// AdminHealthDegrade sets whether /health is forced to report degraded with
+// a 503, as given by ?degraded=true|false, so retrying a call is harmless
+func AdminHealthDegrade(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request to set health degradation")
+
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	degraded, err := strconv.ParseBool(r.URL.Query().Get("degraded"))
+	if err != nil {
+		RespondWithError(w, r, "degraded must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	SetHealthDegraded(degraded)
+	RespondWithJSON(w, HealthDegradeResponse{Degraded: degraded}, http.StatusOK)
+
+	log.Printf("Successfully set health degradation to %t", degraded)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+)
+
+// apiKeyExemptPaths stay open without an API key so probes keep working
+var apiKeyExemptPaths = map[string]bool{
+	"/health":        true,
+	"/health/stream": true,
+	"/healthz":       true,
+	"/ready":         true,
+	"/readyz":        true,
+	"/_ping":         true,
+}
+
+// APIKeyMiddleware requires requests to carry API_KEY in the X-API-Key header
+// or the api_key query parameter, answering others with a 401. Checks are
+// disabled while API_KEY is unset, and never apply to apiKeyExemptPaths or
+// to CORS preflights, which browsers send without custom headers.
+func APIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := os.Getenv("API_KEY")
+		if key == "" || apiKeyExemptPaths[r.URL.Path] || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		given := r.Header.Get("X-API-Key")
+		if given == "" {
+			given = r.URL.Query().Get("api_key")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+			RespondWithError(w, r, "Invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
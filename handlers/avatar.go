@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// defaultAvatarMaxBytes bounds the size of a proxied avatar image
+const defaultAvatarMaxBytes = 1 << 20
+
+// proxyAvatar streams the picture of the first user in body to the client,
+// in the size given by picture_size or large by default, so it can be shown
+// without cross-origin or mixed-content issues. The picture host must be on
+// the upstream allowlist and the image may be at most AVATAR_MAX_BYTES.
+func proxyAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, body []byte) {
+	var users RandomUserResponse
+	pictureURL := ""
+	err := json.Unmarshal(body, &users)
+	if err == nil && len(users.Results) > 0 {
+		pictureURL = users.Results[0].Picture.url(r.URL.Query().Get("picture_size"))
+	}
+	if pictureURL == "" {
+		log.Printf("No avatar in user data: %v", err)
+		RespondWithError(w, r, "Upstream returned no avatar", http.StatusBadGateway)
+		return
+	}
+
+	if err := checkUpstreamAllowed(pictureURL); err != nil {
+		log.Printf("Refusing avatar request: %v", err)
+		RespondWithError(w, r, "Avatar host is not allowed", http.StatusBadGateway)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pictureURL, nil)
+	if err != nil {
+		log.Printf("Error creating avatar request: %v", err)
+		RespondWithError(w, r, "Error fetching avatar", http.StatusBadGateway)
+		return
+	}
+	resp, err := httpClientCreator().Do(req)
+	if err != nil {
+		log.Printf("Error fetching avatar: %v", err)
+		RespondWithError(w, r, "Error fetching avatar", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	maxBytes := int64(envInt("AVATAR_MAX_BYTES", defaultAvatarMaxBytes))
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		err = fmt.Errorf("status %d", resp.StatusCode)
+	case !strings.HasPrefix(contentType, "image/"):
+		err = fmt.Errorf("content type %q is not an image", contentType)
+	case resp.ContentLength > maxBytes:
+		err = fmt.Errorf("%d bytes exceeds the %d byte limit", resp.ContentLength, maxBytes)
+	}
+	if err != nil {
+		log.Printf("Unusable avatar from %s: %v", pictureURL, err)
+		RespondWithError(w, r, "Upstream returned an unusable avatar", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if _, err := io.Copy(w, io.LimitReader(resp.Body, maxBytes)); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
+		return
+	}
+
+	log.Println("Successfully served proxied avatar")
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultRandomBytesSize = 1024
+	// defaultRandomBytesMax caps the size unless RANDOM_BYTES_MAX says otherwise
+	defaultRandomBytesMax = 10 << 20
+)
+
+// RandomBytes handles requests for size random bytes as a binary body
+func RandomBytes(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random bytes")
+
+	max := envInt("RANDOM_BYTES_MAX", defaultRandomBytesMax)
+	size, err := queryInt(r, "size", defaultRandomBytesSize)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if size < 0 || size > max {
+		RespondWithError(w, r, fmt.Sprintf("size must be between 0 and %d", max), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(size))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.CopyN(w, rand.Reader, int64(size)); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
+		return
+	}
+
+	log.Printf("Successfully served %d random bytes", size)
+}
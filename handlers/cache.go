@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheEntry is a cached upstream response and its expiry
+type cacheEntry struct {
+	resp    *upstreamResponse
+	expires time.Time
+}
+
+// responseCache is a TTL cache of upstream responses, safe for concurrent use
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// CacheStats summarizes a cache's contents and effectiveness
+type CacheStats struct {
+	Entries   int     `json:"entries"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	Evictions uint64  `json:"evictions"`
+}
+
+// stats reports the cache's current size and lifetime hit and eviction counts
+func (c *responseCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := CacheStats{Entries: len(c.entries), Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+	if total := c.hits + c.misses; total > 0 {
+		s.HitRate = float64(c.hits) / float64(total)
+	}
+	return s
+}
+
+// get returns the cached response for key if it has not expired
+func (c *responseCache) get(key string) (*upstreamResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		c.evictions++
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return entry.resp, true
+}
+
+// userCacheEnabled reports whether USER_CACHE_TTL and USER_CACHE_MAX_ENTRIES
+// leave the user cache on
+func userCacheEnabled() bool {
+	return envDuration("USER_CACHE_TTL", defaultUserCacheTTL) > 0 &&
+		envInt("USER_CACHE_MAX_ENTRIES", defaultUserCacheMaxEntries) > 0
+}
+
+// set stores resp under key for ttl, evicting expired entries and then the
+// entries closest to expiry once maxEntries is reached. Nothing is stored
+// when maxEntries is zero or less.
+func (c *responseCache) set(key string, resp *upstreamResponse, ttl time.Duration, maxEntries int) {
+	if maxEntries <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+
+	now := time.Now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= maxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+				c.evictions++
+			}
+		}
+		for len(c.entries) >= maxEntries {
+			oldest := ""
+			for k, entry := range c.entries {
+				if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+					oldest = k
+				}
+			}
+			delete(c.entries, oldest)
+			c.evictions++
+		}
+	}
+
+	c.entries[key] = cacheEntry{resp: resp, expires: now.Add(ttl)}
+}
+
+// flush removes every entry and returns how many there were
+func (c *responseCache) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	c.entries = nil
+	c.evictions += uint64(n)
+	return n
+}
+
+// userCache holds seeded User responses, which are deterministic
+var userCache responseCache
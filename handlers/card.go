@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CardValidationRequest is the request body for the card validation endpoint
+type CardValidationRequest struct {
+	Number string `json:"number"`
+}
+
+// CardValidationResponse reports whether a card number is valid and its brand
+type CardValidationResponse struct {
+	Valid bool   `json:"valid"`
+	Brand string `json:"brand"`
+}
+
+// ValidateCard checks a submitted card number's Luhn checksum and brand
+func ValidateCard(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for card validation")
+
+	// Parse request body
+	var body CardValidationRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		RespondWithError(w, r, "Invalid request body: "+describeJSONError(err), jsonErrorStatus(err))
+		return
+	}
+
+	number := normalizeCardNumber(body.Number)
+	if number == "" {
+		RespondWithError(w, r, "number must contain only digits, spaces or dashes", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, CardValidationResponse{
+		Valid: len(number) >= 12 && luhnValid(number),
+		Brand: cardBrand(number),
+	}, http.StatusOK)
+
+	log.Println("Successfully served card validation")
+}
+
+// normalizeCardNumber strips spaces and dashes, returning "" if anything
+// other than digits remains
+func normalizeCardNumber(s string) string {
+	s = strings.NewReplacer(" ", "", "-", "").Replace(s)
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return s
+}
+
+// luhnCheckDigit computes the digit that makes partial pass the Luhn check
+func luhnCheckDigit(partial string) byte {
+	sum := 0
+	for i := len(partial) - 1; i >= 0; i-- {
+		d := int(partial[i] - '0')
+		// Double every second digit counting from the check digit position
+		if (len(partial)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum
+func luhnValid(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	return luhnCheckDigit(number[:len(number)-1]) == number[len(number)-1]
+}
+
+// cardBrand detects the card network from the number's issuer prefix
+func cardBrand(number string) string {
+	prefix := func(n int) int {
+		if len(number) < n {
+			return -1
+		}
+		v, _ := strconv.Atoi(number[:n])
+		return v
+	}
+
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case prefix(2) == 34 || prefix(2) == 37:
+		return "amex"
+	case prefix(2) >= 51 && prefix(2) <= 55, prefix(4) >= 2221 && prefix(4) <= 2720:
+		return "mastercard"
+	case prefix(4) == 6011, prefix(2) == 65, prefix(3) >= 644 && prefix(3) <= 649:
+		return "discover"
+	default:
+		return "unknown"
+	}
+}
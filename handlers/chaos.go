@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultMaxChaosDelay caps the delay a client can request via ?delay=
+const defaultMaxChaosDelay = 10 * time.Second
+
+// ChaosMiddleware injects latency before the request is handled. CHAOS_DELAY
+// sets a fixed delay for every request; when CHAOS_ENABLED=true clients can
+// also request one with ?delay=, clamped to CHAOS_MAX_DELAY. The sleep is
+// abandoned if the request context is cancelled.
+func ChaosMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay := chaosDelay(r)
+		if delay <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		debugf("Injecting chaos delay of %s", delay)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-r.Context().Done():
+			debugf("Request cancelled during chaos delay: %v", r.Context().Err())
+			return
+		case <-timer.C:
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// chaosDelay resolves the delay to inject for r
+func chaosDelay(r *http.Request) time.Duration {
+	delay := envDuration("CHAOS_DELAY", 0)
+
+	if os.Getenv("CHAOS_ENABLED") != "true" {
+		return delay
+	}
+	if value := r.URL.Query().Get("delay"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			delay = d
+		}
+	}
+
+	if max := envDuration("CHAOS_MAX_DELAY", defaultMaxChaosDelay); delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ChoiceResponse is the response body for the random choice endpoint
+type ChoiceResponse struct {
+	Choices []string `json:"choices"`
+}
+
+// weightedOption is a single candidate value with its relative weight
+type weightedOption struct {
+	Value  string
+	Weight float64
+}
+
+// Choice handles requests to pick random values from a list of options. Each
+// option may carry a weight after a colon (a:3,b:1); options without one
+// have a weight of 1, so a list without weights is sampled uniformly.
+func Choice(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random choice")
+
+	options, err := parseWeightedOptions(r.URL.Query().Get("options"))
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCount(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	choices := make([]string, count)
+	for i := range choices {
+		choices[i] = pickWeighted(randomSource(), options)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, ChoiceResponse{Choices: choices}, http.StatusOK)
+
+	log.Println("Successfully served random choice")
+}
+
+// parseWeightedOptions parses a comma-separated option list with optional
+// ":weight" suffixes
+func parseWeightedOptions(raw string) ([]weightedOption, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("options is required")
+	}
+
+	var options []weightedOption
+	total := 0.0
+	for _, item := range strings.Split(raw, ",") {
+		option := weightedOption{Value: strings.TrimSpace(item), Weight: 1}
+		if i := strings.LastIndex(option.Value, ":"); i >= 0 {
+			weight, err := strconv.ParseFloat(option.Value[i+1:], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight in option %q", item)
+			}
+			if math.IsNaN(weight) || math.IsInf(weight, 0) {
+				return nil, fmt.Errorf("weight for option %q must be a finite number", item)
+			}
+			if weight <= 0 {
+				return nil, fmt.Errorf("weight for option %q must be positive", item)
+			}
+			option.Value, option.Weight = option.Value[:i], weight
+		}
+		if option.Value == "" {
+			return nil, fmt.Errorf("options must not be empty")
+		}
+		options = append(options, option)
+		total += option.Weight
+	}
+	if math.IsInf(total, 0) {
+		return nil, fmt.Errorf("weights are too large")
+	}
+	return options, nil
+}
+
+// pickWeighted samples one option with probability proportional to its weight
+func pickWeighted(src randSource, options []weightedOption) string {
+	total := 0.0
+	for _, option := range options {
+		total += option.Weight
+	}
+
+	target := src.Float64() * total
+	for _, option := range options {
+		target -= option.Weight
+		if target < 0 {
+			return option.Value
+		}
+	}
+	return options[len(options)-1].Value
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// defaultUniqueClientsMax bounds how many client IPs are remembered
+const defaultUniqueClientsMax = 10000
+
+// clientIP returns the IP address r was sent from
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// clientSet remembers distinct client IPs, safe for concurrent use. Once
+// full, the client seen first is forgotten to make room for a new one.
+type clientSet struct {
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	order []string
+	next  int
+}
+
+// add records ip, keeping at most max clients
+func (s *clientSet) add(ip string, max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[ip]; ok {
+		return
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]struct{})
+	}
+	if len(s.order) < max {
+		s.order = append(s.order, ip)
+	} else {
+		delete(s.seen, s.order[s.next])
+		s.order[s.next] = ip
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.seen[ip] = struct{}{}
+}
+
+// len returns the number of clients remembered
+func (s *clientSet) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.seen)
+}
+
+// uniqueClients holds the client IPs seen since startup
+var uniqueClients clientSet
+
+// UniqueClientsMiddleware counts the distinct client IPs seen since startup,
+// remembering at most max of them. A max of zero or less disables counting.
+func UniqueClientsMiddleware(max int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uniqueClients.add(clientIP(r), max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
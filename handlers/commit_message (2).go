@@ -1,71 +0,0 @@
-package handlers
-
-import (
-	"context"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"time"
-)
-
-func CommitMessage(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling request for random commit message")
-
-	// Check method
-	if r.Method != http.MethodGet && r.Method != http.MethodOptions {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Handle CORS preflight
-	if r.Method == http.MethodOptions {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	// Set up context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://whatthecommit.com/index.txt", nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	// Send request
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error fetching commit message: %v", err)
-		http.Error(w, "Error fetching commit message", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("API returned non-200 status: %d", resp.StatusCode)
-		http.Error(w, "Upstream API error", http.StatusInternalServerError)
-		return
-	}
-
-	// Set headers
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	// Copy response body to client
-	if _, err := io.Copy(w, resp.Body); err != nil {
-		log.Printf("Error copying response: %v", err)
-		// Cannot write error to client at this point
-		return
-	}
-
-	log.Println("Successfully served random commit message")
-}
\ No newline at end of file
@@ -3,69 +3,131 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// maxCommitMessageAttempts bounds how often a message is re-fetched to satisfy max_length
+const maxCommitMessageAttempts = 5
+
+// Conventional Commits types and scopes picked from for ?conventional=true
+var (
+	conventionalTypes  = []string{"feat", "fix", "chore", "docs"}
+	conventionalScopes = []string{"api", "auth", "build", "cli", "config", "core", "deps", "ui"}
+)
+
+// CommitMessageResponse is the response body for commit messages requested
+// with ?format=json
+type CommitMessageResponse struct {
+	Message string `json:"message"`
+}
+
 func CommitMessage(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request for random commit message")
 
-	// Check method
-	if r.Method != http.MethodGet && r.Method != http.MethodOptions {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	format := requestFormat(r)
+	switch format {
+	case "", "text", "json":
+	default:
+		RespondWithError(w, r, "format must be text or json", http.StatusBadRequest)
 		return
 	}
 
-	// Handle CORS preflight
-	if r.Method == http.MethodOptions {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.WriteHeader(http.StatusOK)
+	// Parse length limit
+	maxLength, err := queryInt(r, "max_length", 0)
+	if err != nil || maxLength < 0 {
+		RespondWithError(w, r, "max_length must be a non-negative integer", http.StatusBadRequest)
 		return
 	}
+	truncate := r.URL.Query().Get("truncate") == "true"
+	conventional := r.URL.Query().Get("conventional") == "true"
 
 	// Set up context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(commitTimeoutEnv))
 	defer cancel()
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://whatthecommit.com/index.txt", nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	// Fetch a message, re-fetching until it fits unless truncation was requested
+	var message string
+	var upstreamDuration time.Duration
+	for attempt := 1; ; attempt++ {
+		resp, err := fetchUpstream(ctx, upstreamURL("COMMIT_MESSAGE_URL", defaultCommitMessageURL))
+		if err != nil {
+			respondUpstreamError(w, err, "commit message")
+			return
+		}
+		message = strings.TrimRight(string(resp.Body), "\r\n")
+		upstreamDuration += resp.Duration
+		if conventional {
+			message = conventionalCommit(randomSource(), message)
+		}
 
-	// Send request
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error fetching commit message: %v", err)
-		http.Error(w, "Error fetching commit message", http.StatusInternalServerError)
-		return
+		if maxLength == 0 || len([]rune(message)) <= maxLength {
+			break
+		}
+		if truncate {
+			message = TruncateAtWord(message, maxLength)
+			break
+		}
+		if attempt == maxCommitMessageAttempts {
+			RespondWithError(w, r, fmt.Sprintf("No commit message within %d characters after %d attempts", maxLength, attempt), http.StatusBadGateway)
+			return
+		}
+		debugf("Commit message exceeds max_length %d, re-fetching (attempt %d)", maxLength, attempt)
 	}
-	defer resp.Body.Close()
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("API returned non-200 status: %d", resp.StatusCode)
-		http.Error(w, "Upstream API error", http.StatusInternalServerError)
+	// Set headers
+	setUpstreamDuration(w, upstreamDuration)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if format == "json" {
+		RespondWithJSON(w, CommitMessageResponse{Message: message}, http.StatusOK)
+		log.Println("Successfully served random commit message as JSON")
 		return
 	}
-
-	// Set headers
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Copy response body to client
-	if _, err := io.Copy(w, resp.Body); err != nil {
-		log.Printf("Error copying response: %v", err)
+	// Write message to client
+	if _, err := w.Write([]byte(message)); err != nil {
+		log.Printf("Error writing response: %v", err)
 		// Cannot write error to client at this point
 		return
 	}
 
 	log.Println("Successfully served random commit message")
-}
\ No newline at end of file
+}
+
+// conventionalCommit formats message as a Conventional Commits header,
+// "<type>(<scope>): <message>", with a random type and an optional random
+// scope. The message is trimmed of surrounding whitespace, line endings
+// included, as CommitMessage already does for the upstream body.
+func conventionalCommit(src randSource, message string) string {
+	prefix := conventionalTypes[src.Intn(len(conventionalTypes))]
+	if src.Intn(2) == 0 {
+		prefix += "(" + conventionalScopes[src.Intn(len(conventionalScopes))] + ")"
+	}
+	return prefix + ": " + strings.TrimSpace(message)
+}
+
+// TruncateAtWord shortens s to at most max characters, cutting at the last
+// word boundary and appending an ellipsis. Strings that already fit are
+// returned unchanged.
+func TruncateAtWord(s string, max int) string {
+	const ellipsis = "..."
+
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	if max <= len(ellipsis) {
+		return string(runes[:max])
+	}
+
+	cut := max - len(ellipsis)
+	// Prefer the last space that keeps the result within the limit
+	if i := strings.LastIndex(string(runes[:cut+1]), " "); i > 0 {
+		return strings.TrimRight(string(runes[:cut+1])[:i], " ") + ellipsis
+	}
+	return string(runes[:cut]) + ellipsis
+}
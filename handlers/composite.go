@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxCompositeCount bounds how many values of each type /random returns,
+// since users are fetched from the upstream in a single batch
+const maxCompositeCount = maxUserCount
+
+// compositeGenerator produces n values of one type for /random
+type compositeGenerator func(ctx context.Context, n int) (interface{}, error)
+
+// compositeGenerators are the types /random can combine, by name
+var compositeGenerators = map[string]compositeGenerator{
+	"user":     compositeUsers,
+	"uuid":     compositeUUIDs,
+	"email":    compositeEmails,
+	"password": compositePasswords,
+}
+
+// compositePart is one "type:count" entry of a /random spec
+type compositePart struct {
+	name  string
+	count int
+}
+
+// Composite handles requests for several data types at once, such as
+// ?types=user:2,uuid:5,email:3, returning each under its type's name
+func Composite(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for composite random data")
+
+	parts, err := parseCompositeSpec(r.URL.Query().Get("types"))
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Set up context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(userTimeoutEnv))
+	defer cancel()
+
+	result := make(map[string]interface{}, len(parts))
+	for _, part := range parts {
+		values, err := compositeGenerators[part.name](ctx, part.count)
+		if err != nil {
+			if part.name == "user" {
+				respondUpstreamError(w, err, "user data")
+				return
+			}
+			log.Printf("Error generating %s values: %v", part.name, err)
+			RespondWithError(w, r, "Error generating "+part.name+" values", http.StatusInternalServerError)
+			return
+		}
+		result[part.name] = values
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, result, http.StatusOK)
+
+	log.Println("Successfully served composite random data")
+}
+
+// parseCompositeSpec parses a comma-separated list of type names, each with
+// an optional ":count" defaulting to 1
+func parseCompositeSpec(spec string) ([]compositePart, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, errors.New("types is required, such as types=user:2,uuid:5")
+	}
+
+	var parts []compositePart
+	seen := map[string]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		name, rawCount, hasCount := strings.Cut(strings.TrimSpace(item), ":")
+		if _, ok := compositeGenerators[name]; !ok {
+			if _, isGenerator := Generator(name); isGenerator {
+				return nil, fmt.Errorf("type %q has its own endpoint, %s%s, and cannot be combined here", name, generatorPrefix, name)
+			}
+			return nil, fmt.Errorf("unknown type %q, must be one of %s", name, strings.Join(compositeTypeNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("type %q is listed more than once", name)
+		}
+		seen[name] = true
+
+		count := 1
+		if hasCount {
+			n, err := strconv.Atoi(rawCount)
+			if err != nil || n < 1 || n > maxCompositeCount {
+				return nil, fmt.Errorf("count for %s must be between 1 and %d", name, maxCompositeCount)
+			}
+			count = n
+		}
+		parts = append(parts, compositePart{name: name, count: count})
+	}
+	return parts, nil
+}
+
+// compositeTypeNames lists the known /random types in alphabetical order
+func compositeTypeNames() []string {
+	names := make([]string, 0, len(compositeGenerators))
+	for name := range compositeGenerators {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// compositeUsers fetches n users from the upstream
+func compositeUsers(ctx context.Context, n int) (interface{}, error) {
+	resp, err := fetchWithFailover(ctx, userUpstreamURLs(userQuery(n, "", nil)))
+	if err != nil {
+		return nil, err
+	}
+	return decodeUserResults(resp.Body)
+}
+
+// compositeUUIDs generates n version 4 UUIDs
+func compositeUUIDs(ctx context.Context, n int) (interface{}, error) {
+	uuids := make([]string, n)
+	for i := range uuids {
+		u, err := newUUIDv4()
+		if err != nil {
+			return nil, err
+		}
+		uuids[i] = formatUUID(u)
+	}
+	return uuids, nil
+}
+
+// compositeEmails generates n email addresses at example.com from the lorem
+// ipsum corpus
+func compositeEmails(ctx context.Context, n int) (interface{}, error) {
+	src := randomSource()
+	emails := make([]string, n)
+	for i := range emails {
+		first := loremWords[src.Intn(len(loremWords))]
+		last := loremWords[src.Intn(len(loremWords))]
+		emails[i] = fmt.Sprintf("%s.%s%d@example.com", first, last, src.Intn(100))
+	}
+	return emails, nil
+}
+
+// compositePasswords generates n passwords with the /random-password defaults
+func compositePasswords(ctx context.Context, n int) (interface{}, error) {
+	policy := PasswordPolicy{Length: 16, Upper: 1, Lower: 1, Digits: 1}
+	passwords := make([]string, n)
+	for i := range passwords {
+		password, err := generatePassword(policy)
+		if err != nil {
+			return nil, err
+		}
+		passwords[i] = password
+	}
+	return passwords, nil
+}
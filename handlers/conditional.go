@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// checkNotModified sets Last-Modified to modified and, when the request's
+// If-Modified-Since is at or after it, answers with a 304 and returns true
+func checkNotModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	// HTTP dates have a resolution of one second
+	modified = modified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
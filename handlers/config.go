@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultMaxInFlight caps concurrent requests unless MAX_IN_FLIGHT says otherwise
+const defaultMaxInFlight = 1000
+
+// Config is a snapshot of the server configuration resolved from the
+// environment. Handlers and middleware read the environment on every
+// request, so a variable changed later takes effect without being reflected
+// here.
+type Config struct {
+	Port            string        `json:"port"`
+	LogPrefix       string        `json:"log_prefix"`
+	HealthStatus    string        `json:"health_force_status"`
+	ReadTimeout     time.Duration `json:"read_timeout"`
+	WriteTimeout    time.Duration `json:"write_timeout"`
+	IdleTimeout     time.Duration `json:"idle_timeout"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	MaxInFlight     int           `json:"max_in_flight"`
+	MaxCount        int           `json:"max_count"`
+	RandomBytesMax  int           `json:"random_bytes_max"`
+	OfflineMaxWords int           `json:"offline_max_words"`
+	Prewarm         bool          `json:"prewarm"`
+
+	SecurityHeaders  map[string]string `json:"security_headers"`
+	UniqueClientsMax int               `json:"unique_clients_max"`
+
+	MaxURLLength        int `json:"max_url_length"`
+	MaxQueryParamLength int `json:"max_query_param_length"`
+	MaxBodyBytes        int `json:"max_body_bytes"`
+
+	ErrorRatioThreshold float64 `json:"error_ratio_threshold"`
+	ErrorRatioWindow    int     `json:"error_ratio_window"`
+
+	SlowRequestThreshold time.Duration `json:"slow_request_threshold"`
+
+	CommitMessageURL string   `json:"commit_message_url"`
+	UserURLs         []string `json:"user_urls"`
+	LoripsumURL      string   `json:"loripsum_url"`
+	LoripsumFallback bool     `json:"loripsum_fallback"`
+	AllowedHosts     []string `json:"allowed_hosts"`
+
+	UpstreamTimeout time.Duration `json:"upstream_timeout"`
+	UserTimeout     time.Duration `json:"user_timeout"`
+	LoripsumTimeout time.Duration `json:"loripsum_timeout"`
+	CommitTimeout   time.Duration `json:"commit_timeout"`
+	DatasetTimeout  time.Duration `json:"dataset_timeout"`
+
+	UpstreamConcurrency int `json:"upstream_concurrency"`
+	UserConcurrency     int `json:"user_concurrency"`
+	LoripsumConcurrency int `json:"loripsum_concurrency"`
+	CommitConcurrency   int `json:"commit_concurrency"`
+
+	UpstreamRetries      int           `json:"upstream_retries"`
+	UpstreamRetryBackoff time.Duration `json:"upstream_retry_backoff"`
+	RetryBudget          int           `json:"retry_budget"`
+	RetryBudgetRefill    time.Duration `json:"retry_budget_refill"`
+
+	UpstreamMaxIdleConns        int           `json:"upstream_max_idle_conns"`
+	UpstreamMaxIdleConnsPerHost int           `json:"upstream_max_idle_conns_per_host"`
+	UpstreamIdleConnTimeout     time.Duration `json:"upstream_idle_conn_timeout"`
+
+	UserCacheTTL        time.Duration `json:"user_cache_ttl"`
+	UserCacheMaxEntries int           `json:"user_cache_max_entries"`
+	UserDownloadMax     int           `json:"user_download_max"`
+	PaginationMaxTotal  int           `json:"pagination_max_total"`
+
+	ChaosEnabled  bool          `json:"chaos_enabled"`
+	ChaosDelay    time.Duration `json:"chaos_delay"`
+	ChaosMaxDelay time.Duration `json:"chaos_max_delay"`
+	Debug         bool          `json:"debug"`
+	DebugBodies   bool          `json:"debug_bodies"`
+}
+
+// LoadConfig resolves the configuration from the environment, applying the
+// same defaults the handlers use
+func LoadConfig() Config {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	return Config{
+		Port:            port,
+		LogPrefix:       os.Getenv("LOG_PREFIX"),
+		HealthStatus:    os.Getenv("HEALTH_FORCE_STATUS"),
+		ReadTimeout:     envDuration("READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:    envDuration("WRITE_TIMEOUT", 15*time.Second),
+		IdleTimeout:     envDuration("IDLE_TIMEOUT", 60*time.Second),
+		ShutdownTimeout: envDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		MaxInFlight:     envInt("MAX_IN_FLIGHT", defaultMaxInFlight),
+		MaxCount:        envInt("MAX_COUNT", defaultMaxCount),
+		RandomBytesMax:  envInt("RANDOM_BYTES_MAX", defaultRandomBytesMax),
+		OfflineMaxWords: offlineMaxWords(),
+		Prewarm:         os.Getenv("PREWARM") == "true",
+
+		SecurityHeaders:  securityHeaders(),
+		UniqueClientsMax: envInt("UNIQUE_CLIENTS_MAX", defaultUniqueClientsMax),
+
+		MaxURLLength:        envInt("MAX_URL_LENGTH", defaultMaxURLLength),
+		MaxQueryParamLength: envInt("MAX_QUERY_PARAM_LENGTH", defaultMaxQueryParamLength),
+		MaxBodyBytes:        envInt("MAX_BODY_BYTES", defaultMaxBodyBytes),
+
+		ErrorRatioThreshold: envFloat("ERROR_RATIO_THRESHOLD", defaultErrorRatioThreshold),
+		ErrorRatioWindow:    envInt("ERROR_RATIO_WINDOW", defaultErrorRatioWindow),
+
+		SlowRequestThreshold: envDuration("SLOW_REQUEST_THRESHOLD", defaultSlowRequestThreshold),
+
+		CommitMessageURL: upstreamURL("COMMIT_MESSAGE_URL", defaultCommitMessageURL),
+		UserURLs:         upstreamURLs("USER_URLS", "USER_URL", defaultUserURL),
+		LoripsumURL:      upstreamURL("LORIPSUM_URL", defaultLoripsumURL),
+		LoripsumFallback: os.Getenv("LORIPSUM_FALLBACK") == "true",
+		AllowedHosts:     allowedUpstreamHosts(),
+
+		UpstreamTimeout: envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout),
+		UserTimeout:     upstreamTimeout(userTimeoutEnv),
+		LoripsumTimeout: upstreamTimeout(loripsumTimeoutEnv),
+		CommitTimeout:   upstreamTimeout(commitTimeoutEnv),
+		DatasetTimeout:  envDuration("DATASET_TIMEOUT", defaultDatasetTimeout),
+
+		UpstreamConcurrency: envInt("UPSTREAM_CONCURRENCY", 0),
+		UserConcurrency:     envInt(userConcurrencyEnv, envInt("UPSTREAM_CONCURRENCY", 0)),
+		LoripsumConcurrency: envInt(loripsumConcurrencyEnv, envInt("UPSTREAM_CONCURRENCY", 0)),
+		CommitConcurrency:   envInt(commitConcurrencyEnv, envInt("UPSTREAM_CONCURRENCY", 0)),
+
+		UpstreamRetries:      envInt("UPSTREAM_RETRIES", defaultUpstreamRetries),
+		UpstreamRetryBackoff: envDuration("UPSTREAM_RETRY_BACKOFF", defaultUpstreamRetryBackoff),
+		RetryBudget:          envInt("RETRY_BUDGET", defaultRetryBudget),
+		RetryBudgetRefill:    envDuration("RETRY_BUDGET_REFILL", defaultRetryBudgetRefill),
+
+		UpstreamMaxIdleConns:        envInt("UPSTREAM_MAX_IDLE_CONNS", defaultUpstreamMaxIdleConns),
+		UpstreamMaxIdleConnsPerHost: envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", defaultUpstreamMaxIdleConnsPerHost),
+		UpstreamIdleConnTimeout:     envDuration("UPSTREAM_IDLE_CONN_TIMEOUT", defaultUpstreamIdleConnTimeout),
+
+		UserCacheTTL:        envDuration("USER_CACHE_TTL", defaultUserCacheTTL),
+		UserCacheMaxEntries: envInt("USER_CACHE_MAX_ENTRIES", defaultUserCacheMaxEntries),
+		UserDownloadMax:     envInt("USER_DOWNLOAD_MAX", defaultUserDownloadMax),
+		PaginationMaxTotal:  envInt("PAGINATION_MAX_TOTAL", defaultPaginationMaxTotal),
+
+		ChaosEnabled:  os.Getenv("CHAOS_ENABLED") == "true",
+		ChaosDelay:    envDuration("CHAOS_DELAY", 0),
+		ChaosMaxDelay: envDuration("CHAOS_MAX_DELAY", defaultMaxChaosDelay),
+		Debug:         DebugEnabled(),
+		DebugBodies:   os.Getenv("DEBUG_BODIES") == "true",
+	}
+}
+
+// MarshalJSON renders durations in their human-readable form
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	return json.Marshal(struct {
+		plain
+		ReadTimeout             string `json:"read_timeout"`
+		WriteTimeout            string `json:"write_timeout"`
+		IdleTimeout             string `json:"idle_timeout"`
+		ShutdownTimeout         string `json:"shutdown_timeout"`
+		SlowRequestThreshold    string `json:"slow_request_threshold"`
+		UpstreamTimeout         string `json:"upstream_timeout"`
+		UserTimeout             string `json:"user_timeout"`
+		LoripsumTimeout         string `json:"loripsum_timeout"`
+		CommitTimeout           string `json:"commit_timeout"`
+		DatasetTimeout          string `json:"dataset_timeout"`
+		UpstreamRetryBackoff    string `json:"upstream_retry_backoff"`
+		RetryBudgetRefill       string `json:"retry_budget_refill"`
+		UpstreamIdleConnTimeout string `json:"upstream_idle_conn_timeout"`
+		UserCacheTTL            string `json:"user_cache_ttl"`
+		ChaosDelay              string `json:"chaos_delay"`
+		ChaosMaxDelay           string `json:"chaos_max_delay"`
+	}{
+		plain:                   plain(c),
+		ReadTimeout:             c.ReadTimeout.String(),
+		WriteTimeout:            c.WriteTimeout.String(),
+		IdleTimeout:             c.IdleTimeout.String(),
+		ShutdownTimeout:         c.ShutdownTimeout.String(),
+		SlowRequestThreshold:    c.SlowRequestThreshold.String(),
+		UpstreamTimeout:         c.UpstreamTimeout.String(),
+		UserTimeout:             c.UserTimeout.String(),
+		LoripsumTimeout:         c.LoripsumTimeout.String(),
+		CommitTimeout:           c.CommitTimeout.String(),
+		DatasetTimeout:          c.DatasetTimeout.String(),
+		UpstreamRetryBackoff:    c.UpstreamRetryBackoff.String(),
+		RetryBudgetRefill:       c.RetryBudgetRefill.String(),
+		UpstreamIdleConnTimeout: c.UpstreamIdleConnTimeout.String(),
+		UserCacheTTL:            c.UserCacheTTL.String(),
+		ChaosDelay:              c.ChaosDelay.String(),
+		ChaosMaxDelay:           c.ChaosMaxDelay.String(),
+	})
+}
+
+// LogConfig logs the configuration snapshot as a single JSON line
+func LogConfig(c Config) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		log.Printf("Error encoding configuration: %v", err)
+		return
+	}
+	log.Printf("Configuration at startup: %s", data)
+}
+
+// allowedUpstreamHosts returns the default upstream hosts plus any listed in
+// UPSTREAM_ALLOWED_HOSTS
+func allowedUpstreamHosts() []string {
+	hosts := append([]string{}, defaultAllowedHosts...)
+	for _, h := range strings.Split(os.Getenv("UPSTREAM_ALLOWED_HOSTS"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
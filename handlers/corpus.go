@@ -0,0 +1,17 @@
+package handlers
+
+// loremWords is the word corpus used by the offline generators
+var loremWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+	"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore",
+	"magna", "aliqua", "enim", "ad", "minim", "veniam", "quis", "nostrud",
+	"exercitation", "ullamco", "laboris", "nisi", "aliquip", "ex", "ea", "commodo",
+	"consequat", "duis", "aute", "irure", "in", "reprehenderit", "voluptate",
+	"velit", "esse", "cillum", "fugiat", "nulla", "pariatur", "excepteur", "sint",
+	"occaecat", "cupidatat", "non", "proident", "sunt", "culpa", "qui", "officia",
+	"deserunt", "mollit", "anim", "id", "est", "laborum", "quid", "ergo", "aliud",
+	"intellegetur", "uti", "ne", "quae", "pars", "naturae", "neglegatur", "tamen",
+	"haec", "omnia", "sapiens", "virtus", "beata", "vita", "summum", "bonum",
+	"voluptas", "dolorem", "natura", "ratio", "animus", "corpus", "sententia",
+	"philosophia", "disciplina", "honestum", "utilitas", "officium", "cupiditas",
+}
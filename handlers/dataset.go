@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDatasetCount bounds the users and commit messages in one dataset, in
+// place of MAX_COUNT, since each commit message is its own upstream request
+const maxDatasetCount = 10
+
+// defaultDatasetTimeout bounds a whole dataset request, across all of its
+// upstream fetches, unless DATASET_TIMEOUT says otherwise
+const defaultDatasetTimeout = 10 * time.Second
+
+// Dataset bundles users, commit messages and lorem ipsum in one response
+type Dataset struct {
+	Users   UserBatch `json:"users"`
+	Commits []string  `json:"commits"`
+	Lorem   string    `json:"lorem"`
+}
+
+// RandomDataset handles requests for a bundle of count users and commit
+// messages plus lorem ipsum, as JSON or, with ?format=zip, as a ZIP holding
+// users.json, commits.txt and lorem.html
+func RandomDataset(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random dataset")
+
+	format := requestFormat(r)
+	switch format {
+	case "", "json", "zip":
+	default:
+		RespondWithError(w, r, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCountMax(r, maxDatasetCount)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), envDuration("DATASET_TIMEOUT", defaultDatasetTimeout))
+	defer cancel()
+
+	dataset, err := fetchDataset(ctx, count)
+	if err != nil {
+		respondUpstreamError(w, err, "dataset")
+		return
+	}
+	if r.URL.Query().Get("ordered") == "true" {
+		if err := orderDataset(&dataset); err != nil {
+			log.Printf("Error decoding user data: %v", err)
+			RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if format != "zip" {
+		RespondWithJSON(w, dataset, http.StatusOK)
+		log.Println("Successfully served random dataset")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="dataset.zip"`)
+	if err := writeDatasetZip(w, dataset); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
+		return
+	}
+
+	log.Println("Successfully served random dataset as zip")
+}
+
+// fetchDataset gathers count users and commit messages and one lorem
+// ipsum document from the upstreams, each within its upstream's timeout and
+// all within ctx
+func fetchDataset(ctx context.Context, count int) (Dataset, error) {
+	var dataset Dataset
+
+	resp, err := fetchWithTimeout(ctx, userTimeoutEnv, func(ctx context.Context) (*upstreamResponse, error) {
+		return fetchWithFailover(ctx, userUpstreamURLs(userQuery(count, "", nil)))
+	})
+	if err != nil {
+		return dataset, err
+	}
+	if dataset.Users, err = decodeUserBatch(resp.Body); err != nil {
+		return dataset, err
+	}
+
+	for i := 0; i < count; i++ {
+		resp, err := fetchWithTimeout(ctx, commitTimeoutEnv, func(ctx context.Context) (*upstreamResponse, error) {
+			return fetchUpstream(ctx, upstreamURL("COMMIT_MESSAGE_URL", defaultCommitMessageURL))
+		})
+		if err != nil {
+			return dataset, err
+		}
+		dataset.Commits = append(dataset.Commits, strings.TrimRight(string(resp.Body), "\n"))
+	}
+
+	u, err := url.Parse(upstreamURL("LORIPSUM_URL", defaultLoripsumURL))
+	if err != nil {
+		return dataset, err
+	}
+	u.Path = buildLoripsumPath(&LoripsumParams{})
+	resp, err = fetchWithTimeout(ctx, loripsumTimeoutEnv, func(ctx context.Context) (*upstreamResponse, error) {
+		return fetchUpstreamShared(ctx, u.String())
+	})
+	if err != nil {
+		return dataset, err
+	}
+	dataset.Lorem = string(resp.Body)
+
+	return dataset, nil
+}
+
+// orderDataset sorts the dataset's users by userSortKey and its commit
+// messages alphabetically
+func orderDataset(dataset *Dataset) error {
+	type keyedUser struct {
+		key string
+		raw json.RawMessage
+	}
+	users := make([]keyedUser, len(dataset.Users.Results))
+	for i, raw := range dataset.Users.Results {
+		var user map[string]interface{}
+		if err := json.Unmarshal(raw, &user); err != nil {
+			return err
+		}
+		users[i] = keyedUser{key: userSortKey(user), raw: raw}
+	}
+	sort.SliceStable(users, func(i, j int) bool { return users[i].key < users[j].key })
+	for i, user := range users {
+		dataset.Users.Results[i] = user.raw
+	}
+	sort.Strings(dataset.Commits)
+	return nil
+}
+
+// fetchWithTimeout runs fetch bounded by the upstream timeout in key
+func fetchWithTimeout(ctx context.Context, key string, fetch func(context.Context) (*upstreamResponse, error)) (*upstreamResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamTimeout(key))
+	defer cancel()
+	return fetch(ctx)
+}
+
+// writeDatasetZip streams dataset to w as a ZIP archive
+func writeDatasetZip(w http.ResponseWriter, dataset Dataset) error {
+	users, err := json.MarshalIndent(dataset.Users, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		body string
+	}{
+		{"users.json", string(users) + "\n"},
+		{"commits.txt", strings.Join(dataset.Commits, "\n") + "\n"},
+		{"lorem.html", dataset.Lorem},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write([]byte(f.body)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
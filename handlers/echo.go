@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// EchoResponse describes the request the echo endpoint received
+type EchoResponse struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Headers http.Header `json:"headers"`
+	Query   url.Values  `json:"query"`
+	Body    string      `json:"body"`
+}
+
+// Echo returns the request it received so clients can check what they sent.
+// Credential headers and query parameters are redacted.
+func Echo(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request to echo")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		RespondWithError(w, r, "Invalid request body: "+describeJSONError(err), jsonErrorStatus(err))
+		return
+	}
+
+	RespondWithJSON(w, EchoResponse{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Headers: redactHeaders(r.Header),
+		Query:   redactQuery(r.URL.Query()),
+		Body:    string(body),
+	}, http.StatusOK)
+
+	log.Println("Successfully echoed request")
+}
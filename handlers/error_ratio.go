@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Default error ratio alerting
+const (
+	defaultErrorRatioThreshold = 0.5
+	defaultErrorRatioWindow    = 100
+	// maxErrorRatioEndpoints bounds how many distinct paths are tracked
+	maxErrorRatioEndpoints = 1000
+)
+
+// outcomeWindow is a ring buffer of the most recent request outcomes for
+// one endpoint
+type outcomeWindow struct {
+	failed   []bool
+	next     int
+	filled   int
+	failures int
+	alerting bool
+}
+
+// record adds an outcome, evicting the oldest once the window is full, and
+// returns the error ratio over the window
+func (o *outcomeWindow) record(failed bool) float64 {
+	if o.filled == len(o.failed) {
+		if o.failed[o.next] {
+			o.failures--
+		}
+	} else {
+		o.filled++
+	}
+	o.failed[o.next] = failed
+	if failed {
+		o.failures++
+	}
+	o.next = (o.next + 1) % len(o.failed)
+	return float64(o.failures) / float64(o.filled)
+}
+
+// ErrorRatioMiddleware tracks the outcome of the last window requests to each
+// path and logs a warning when the share of 5xx responses exceeds threshold.
+// The warning is logged once when the ratio crosses the threshold and again
+// only after it has recovered. A threshold or window of zero or less
+// disables tracking.
+func ErrorRatioMiddleware(threshold float64, window int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if threshold <= 0 || window <= 0 {
+			return next
+		}
+
+		var mu sync.Mutex
+		windows := make(map[string]*outcomeWindow)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			o, ok := windows[r.URL.Path]
+			if !ok {
+				if len(windows) >= maxErrorRatioEndpoints {
+					return
+				}
+				o = &outcomeWindow{failed: make([]bool, window)}
+				windows[r.URL.Path] = o
+			}
+
+			// Judge the ratio only once the window has filled up
+			ratio := o.record(rec.status >= http.StatusInternalServerError)
+			if o.filled < window {
+				return
+			}
+			switch {
+			case ratio > threshold && !o.alerting:
+				o.alerting = true
+				log.Printf("WARN: %s error ratio %.2f over the last %d requests exceeds %.2f", r.URL.Path, ratio, window, threshold)
+			case ratio <= threshold && o.alerting:
+				o.alerting = false
+				log.Printf("%s error ratio recovered to %.2f over the last %d requests", r.URL.Path, ratio, window)
+			}
+		})
+	}
+}
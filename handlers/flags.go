@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+)
+
+// maxFlagCount bounds the flags in one payload, in place of MAX_COUNT, so
+// keys stay unique within the corpus
+const maxFlagCount = 100
+
+// flagPrefixes start every generated flag key
+var flagPrefixes = []string{"enable_", "use_"}
+
+// Flags handles requests for a random feature-flag payload: an object of
+// flag keys mapped to booleans or, occasionally, rollout percentages
+func Flags(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random feature flags")
+
+	count, err := parseCountMax(r, maxFlagCount)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	src := randomSource()
+	flags := make(map[string]interface{}, count)
+	for len(flags) < count {
+		key := flagPrefixes[src.Intn(len(flagPrefixes))] + loremWords[src.Intn(len(loremWords))]
+		if src.Intn(5) == 0 {
+			flags[key] = src.Intn(101)
+		} else {
+			flags[key] = src.Intn(2) == 1
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, flags, http.StatusOK)
+
+	log.Println("Successfully served random feature flags")
+}
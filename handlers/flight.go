@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+// flightCall is an upstream fetch that concurrent callers can wait on
+type flightCall struct {
+	done chan struct{}
+	resp *upstreamResponse
+	err  error
+}
+
+// flightGroup deduplicates concurrent fetches for the same key so that only
+// one of them reaches the upstream, in the manner of singleflight
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do runs fn once for all concurrent callers sharing key. fn runs under a
+// context that keeps the first caller's values and deadline but not its
+// cancellation, so one client going away does not fail the others; each
+// caller, the first included, stops waiting when its own context is done.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (*upstreamResponse, error)) (*upstreamResponse, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, ok := g.calls[key]
+	if ok {
+		debugf("Joining in-flight upstream request for %s", key)
+	} else {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		shared, cancel := sharedContext(ctx)
+		go func() {
+			defer cancel()
+			call.resp, call.err = fn(shared)
+			g.finish(key, call)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// finish releases every caller waiting on the completed call
+func (g *flightGroup) finish(key string, call *flightCall) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+}
+
+// sharedContext derives the context for a shared fetch from ctx, dropping its
+// cancellation but keeping its deadline, or UPSTREAM_TIMEOUT without one
+func sharedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout))
+}
+
+// upstreamFlights shares identical in-flight upstream requests
+var upstreamFlights flightGroup
+
+// fetchUpstreamShared is fetchUpstream with concurrent identical requests
+// collapsed into a single upstream call keyed by URL
+func fetchUpstreamShared(ctx context.Context, rawURL string) (*upstreamResponse, error) {
+	return upstreamFlights.do(ctx, rawURL, func(ctx context.Context) (*upstreamResponse, error) {
+		return fetchUpstream(ctx, rawURL)
+	})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// generatorPrefix starts the path of every generator route
+const generatorPrefix = "/random-"
+
+// generators returns the generator registry, mapping each generator's name to
+// its handler. Every /random-<name> route in the route table is registered
+// under <name>, so adding a route is all it takes to add a generator.
+func generators() map[string]http.HandlerFunc {
+	registry := map[string]http.HandlerFunc{}
+	for _, route := range Routes() {
+		if name, ok := strings.CutPrefix(route.Path, generatorPrefix); ok {
+			registry[name] = route.Handler
+		}
+	}
+	return registry
+}
+
+// Generator returns the handler of the generator registered under name
+func Generator(name string) (http.HandlerFunc, bool) {
+	h, ok := generators()[name]
+	return h, ok
+}
+
+// GeneratorNames returns the registered generator names in alphabetical order
+func GeneratorNames() []string {
+	registry := generators()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"net/http"
+)
+
+// writeBody writes body, gzip-compressing it with Content-Encoding: gzip when
+// the request asks for ?gzip=true regardless of its Accept-Encoding
+func writeBody(w http.ResponseWriter, r *http.Request, body []byte) error {
+	if r.URL.Query().Get("gzip") != "true" {
+		_, err := w.Write(body)
+		return err
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	return zw.Close()
+}
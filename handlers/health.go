@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +21,10 @@ type HealthStatus struct {
 	Uptime    string    `json:"uptime"`
 	GoVersion string    `json:"go_version"`
 	Memory    MemStats  `json:"memory"`
+	// Cache is reported only while the user cache is enabled
+	Cache *CacheStats `json:"cache,omitempty"`
+	// Features reports which optional behaviors this deployment has enabled
+	Features map[string]bool `json:"features"`
 }
 
 // MemStats contains memory statistics
@@ -28,15 +37,85 @@ type MemStats struct {
 
 var startTime = time.Now()
 
+// healthDegraded forces /health to report degraded, for testing alerting.
+// It is set by the admin degrade endpoint.
+var healthDegraded atomic.Bool
+
+// SetHealthDegraded sets whether /health is forced to report degraded
+func SetHealthDegraded(degraded bool) {
+	healthDegraded.Store(degraded)
+}
+
+// warnedHealthStatuses holds the unknown HEALTH_FORCE_STATUS values already
+// warned about, so probes do not repeat the warning on every request
+var warnedHealthStatuses sync.Map
+
+// healthFeatures reports which optional behaviors are enabled by the
+// environment. rate_limit is the MAX_IN_FLIGHT cap, and tls whether the
+// request reached this server over TLS rather than through a terminating proxy.
+func healthFeatures(r *http.Request) map[string]bool {
+	return map[string]bool{
+		"caching":    userCacheEnabled(),
+		"fallback":   os.Getenv("LORIPSUM_FALLBACK") == "true",
+		"retries":    envInt("UPSTREAM_RETRIES", defaultUpstreamRetries) > 0,
+		"rate_limit": envInt("MAX_IN_FLIGHT", defaultMaxInFlight) > 0,
+		"tls":        r.TLS != nil,
+	}
+}
+
+// healthForcedDegraded reports whether /health should report degraded, either
+// set by an admin or forced with HEALTH_FORCE_STATUS=degraded. Values other
+// than ok and degraded are ignored with a warning.
+func healthForcedDegraded() bool {
+	if healthDegraded.Load() {
+		return true
+	}
+	value := os.Getenv("HEALTH_FORCE_STATUS")
+	switch strings.ToLower(value) {
+	case "", "ok":
+		return false
+	case "degraded":
+		return true
+	}
+	if _, warned := warnedHealthStatuses.LoadOrStore(value, true); !warned {
+		log.Printf("WARN: ignoring unknown HEALTH_FORCE_STATUS=%q, want ok or degraded", value)
+	}
+	return false
+}
+
 // Health handles health check requests
 func Health(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling health check request")
 
+	status, code := currentHealth(r)
+
+	if requestFormat(r) == "yaml" {
+		RespondWithYAML(w, status, code)
+		log.Println("Successfully served health check")
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	// Encode response to JSON
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Error encoding health status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully served health check")
+}
+
+// currentHealth builds the health status for r and the HTTP status code to
+// report it with
+func currentHealth(r *http.Request) (HealthStatus, int) {
 	// Get runtime memory stats
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	// Build health status response
 	status := HealthStatus{
 		Status:    "ok",
 		Version:   "1.0.0",
@@ -49,18 +128,94 @@ func Health(w http.ResponseWriter, r *http.Request) {
 			Sys:        m.Sys,
 			NumGC:      m.NumGC,
 		},
+		Features: healthFeatures(r),
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	if userCacheEnabled() {
+		stats := userCache.stats()
+		status.Cache = &stats
+	}
 
-	// Encode response to JSON
-	if err := json.NewEncoder(w).Encode(status); err != nil {
-		log.Printf("Error encoding health status: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	code := http.StatusOK
+	if healthForcedDegraded() {
+		status.Status = "degraded"
+		code = http.StatusServiceUnavailable
+	}
+	return status, code
+}
+
+// Bounds and default for ?interval on /health/stream
+const (
+	defaultHealthStreamInterval = 5 * time.Second
+	minHealthStreamInterval     = time.Second
+)
+
+// HealthStream pushes the health status as a Server-Sent Event every
+// ?interval, starting immediately, until the client disconnects
+func HealthStream(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling health stream request")
+
+	interval := defaultHealthStreamInterval
+	if raw := r.URL.Query().Get("interval"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < minHealthStreamInterval {
+			RespondWithError(w, r, fmt.Sprintf("interval must be a duration of at least %s", minHealthStreamInterval), http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		RespondWithError(w, r, "Streaming is not supported", http.StatusInternalServerError)
 		return
 	}
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		debugf("Cannot clear the write deadline for the health stream: %v", err)
+	}
 
-	log.Println("Successfully served health check")
-}
\ No newline at end of file
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for events := 0; ; events++ {
+		status, _ := currentHealth(r)
+		data, err := json.Marshal(status)
+		if err != nil {
+			log.Printf("Error encoding health status: %v", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: health\ndata: %s\n\n", data); err != nil {
+			log.Printf("Error writing response: %v", err)
+			// Cannot write error to client at this point
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			log.Printf("Health stream closed by client after %d events", events+1)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ping handles liveness checks
+func Ping(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// ReadyStatus represents the readiness status
+type ReadyStatus struct {
+	Status string `json:"status"`
+}
+
+// Ready handles readiness probes, reporting whether the server can accept traffic
+func Ready(w http.ResponseWriter, r *http.Request) {
+	RespondWithJSON(w, ReadyStatus{Status: "ready"}, http.StatusOK)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Environment variables capping concurrent requests to each upstream host,
+// falling back to UPSTREAM_CONCURRENCY. A limit of zero or less means no cap.
+const (
+	userConcurrencyEnv     = "USER_CONCURRENCY"
+	loripsumConcurrencyEnv = "LORIPSUM_CONCURRENCY"
+	commitConcurrencyEnv   = "COMMIT_CONCURRENCY"
+)
+
+// hostSemaphoreKey identifies a host's semaphore. The size is part of the key
+// so a changed limit takes effect with a fresh semaphore.
+type hostSemaphoreKey struct {
+	host string
+	size int
+}
+
+// hostSemaphores holds one semaphore per upstream host and limit
+var hostSemaphores = struct {
+	sync.Mutex
+	m map[hostSemaphoreKey]chan struct{}
+}{m: map[hostSemaphoreKey]chan struct{}{}}
+
+// hostConcurrency returns the concurrency limit for requests to host: that of
+// the first upstream configured on host, else UPSTREAM_CONCURRENCY
+func hostConcurrency(host string) int {
+	upstreams := []struct {
+		key  string
+		urls []string
+	}{
+		{userConcurrencyEnv, upstreamURLs("USER_URLS", "USER_URL", defaultUserURL)},
+		{loripsumConcurrencyEnv, []string{upstreamURL("LORIPSUM_URL", defaultLoripsumURL)}},
+		{commitConcurrencyEnv, []string{upstreamURL("COMMIT_MESSAGE_URL", defaultCommitMessageURL)}},
+	}
+	fallback := envInt("UPSTREAM_CONCURRENCY", 0)
+	for _, upstream := range upstreams {
+		for _, raw := range upstream.urls {
+			if u, err := url.Parse(raw); err == nil && strings.EqualFold(u.Hostname(), host) {
+				return envInt(upstream.key, fallback)
+			}
+		}
+	}
+	return fallback
+}
+
+// acquireHost waits for a free slot for a request to rawURL's host, returning
+// a function that releases it. It gives up when ctx is done.
+func acquireHost(ctx context.Context, rawURL string) (func(), error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.ToLower(u.Hostname())
+	size := hostConcurrency(host)
+	if size <= 0 {
+		return func() {}, nil
+	}
+
+	key := hostSemaphoreKey{host: host, size: size}
+	hostSemaphores.Lock()
+	sem, ok := hostSemaphores.m[key]
+	if !ok {
+		sem = make(chan struct{}, size)
+		hostSemaphores.m[key] = sem
+	}
+	hostSemaphores.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	default:
+	}
+	debugf("Upstream %s is at its concurrency limit of %d, waiting", host, size)
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"html"
+	"regexp"
+)
+
+// htmlTagPattern matches any HTML tag
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML removes all tags from s and decodes its entities, leaving only
+// the visible text
+func stripHTML(s string) string {
+	return html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+}
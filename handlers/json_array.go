@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultJSONArrayLength = 10
+	maxJSONArrayLength     = 1000
+
+	// maxJSONObjectKeys bounds the number of keys in generated objects
+	maxJSONObjectKeys = 5
+	// jsonArrayIntMax bounds generated integers
+	jsonArrayIntMax = 1000000
+)
+
+// JSONArray handles requests for a JSON array whose elements all have the
+// requested type
+func JSONArray(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random JSON array")
+
+	var element func(randSource) interface{}
+	switch r.URL.Query().Get("type") {
+	case "", "int":
+		element = func(src randSource) interface{} { return src.Intn(jsonArrayIntMax) }
+	case "string":
+		element = func(src randSource) interface{} { return randomJSONString(src) }
+	case "object":
+		element = func(src randSource) interface{} { return randomJSONObject(src) }
+	default:
+		RespondWithError(w, r, "type must be int, string or object", http.StatusBadRequest)
+		return
+	}
+
+	length, err := queryInt(r, "length", defaultJSONArrayLength)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if length < 0 || length > maxJSONArrayLength {
+		RespondWithError(w, r, fmt.Sprintf("length must be between 0 and %d", maxJSONArrayLength), http.StatusBadRequest)
+		return
+	}
+
+	src := randomSource()
+	array := make([]interface{}, length)
+	for i := range array {
+		array[i] = element(src)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, array, http.StatusOK)
+
+	log.Println("Successfully served random JSON array")
+}
+
+// randomJSONString returns one to three words from the corpus
+func randomJSONString(src randSource) string {
+	words := make([]string, 1+src.Intn(3))
+	for i := range words {
+		words[i] = loremWords[src.Intn(len(loremWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+// randomJSONObject returns a flat object keyed by corpus words with scalar
+// values of mixed types
+func randomJSONObject(src randSource) map[string]interface{} {
+	n := 1 + src.Intn(maxJSONObjectKeys)
+	obj := make(map[string]interface{}, n)
+	for len(obj) < n {
+		key := loremWords[src.Intn(len(loremWords))]
+		switch src.Intn(4) {
+		case 0:
+			obj[key] = src.Intn(jsonArrayIntMax)
+		case 1:
+			obj[key] = src.Float64()
+		case 2:
+			obj[key] = src.Intn(2) == 1
+		default:
+			obj[key] = randomJSONString(src)
+		}
+	}
+	return obj
+}
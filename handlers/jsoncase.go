@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// bufferedWriter holds back the status and body of a JSON response so it can
+// be transformed before it is sent. Whether the response is JSON is decided
+// from its Content-Type when the header is written; any other response, such
+// as an event stream or an NDJSON download, passes straight through.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status    int
+	buffering bool
+	buf       bytes.Buffer
+}
+
+func (b *bufferedWriter) WriteHeader(code int) {
+	if b.status != 0 {
+		return
+	}
+	b.status = code
+	b.buffering = strings.HasPrefix(b.Header().Get("Content-Type"), "application/json")
+	if !b.buffering {
+		b.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if b.buffering {
+		return b.buf.Write(p)
+	}
+	return b.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming responses streaming; buffered JSON is only sent
+// once the handler is done
+func (b *bufferedWriter) Flush() {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if f, ok := b.ResponseWriter.(http.Flusher); ok && !b.buffering {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (b *bufferedWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// CaseMiddleware re-keys JSON responses to camelCase when the request has
+// ?case=camel. snake_case, the default, passes responses through untouched.
+func CaseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("case") {
+		case "", "snake":
+			next.ServeHTTP(w, r)
+			return
+		case "camel":
+		default:
+			RespondWithError(w, r, "case must be snake or camel", http.StatusBadRequest)
+			return
+		}
+
+		serveRewrittenJSON(w, r, next, camelKeys)
+	})
+}
+
+// NumbersMiddleware encodes every number in JSON responses as a string when
+// the request has ?numbers_as_strings=true, so JavaScript clients do not lose
+// precision on large IDs
+func NumbersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("numbers_as_strings") {
+		case "", "false":
+			next.ServeHTTP(w, r)
+			return
+		case "true":
+		default:
+			RespondWithError(w, r, "numbers_as_strings must be true or false", http.StatusBadRequest)
+			return
+		}
+
+		serveRewrittenJSON(w, r, next, quoteNumbers)
+	})
+}
+
+// serveRewrittenJSON buffers next's response and, if it is JSON, passes the
+// decoded body through rewrite before sending it. Other responses are sent
+// as next writes them. Numbers are decoded as json.Number so they keep their
+// exact digits.
+func serveRewrittenJSON(w http.ResponseWriter, r *http.Request, next http.Handler, rewrite func(interface{}) interface{}) {
+	bw := &bufferedWriter{ResponseWriter: w}
+	next.ServeHTTP(bw, r)
+	if !bw.buffering {
+		return
+	}
+
+	body := bw.buf.Bytes()
+	var data interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err == nil {
+		if out, err := json.Marshal(rewrite(data)); err == nil {
+			body = append(out, '\n')
+		} else {
+			log.Printf("Error re-encoding rewritten response: %v", err)
+		}
+	}
+
+	w.Header().Del("Content-Length")
+	w.WriteHeader(bw.status)
+	w.Write(body)
+}
+
+// camelKeys recursively converts every object key in v to camelCase
+func camelKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[snakeToCamel(k)] = camelKeys(val)
+		}
+		return out
+	case []interface{}:
+		for i, val := range v {
+			v[i] = camelKeys(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// quoteNumbers recursively replaces every number in v with its string form
+func quoteNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = quoteNumbers(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = quoteNumbers(val)
+		}
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// snakeToCamel converts a snake_case identifier to camelCase
+func snakeToCamel(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
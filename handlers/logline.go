@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// logLineWindow is how far back generated timestamps may lie
+	logLineWindow = 24 * time.Hour
+
+	apacheTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+// LogLine is a single generated HTTP access log entry
+type LogLine struct {
+	RemoteAddr string    `json:"remote_addr"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Protocol   string    `json:"protocol"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	Referer    string    `json:"referer"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// Weighted distributions for the generated request fields
+var (
+	logLineMethods = []weightedOption{
+		{"GET", 80}, {"POST", 12}, {"PUT", 4}, {"DELETE", 3}, {"PATCH", 1},
+	}
+	logLineStatuses = []weightedOption{
+		{"200", 75}, {"201", 4}, {"204", 3}, {"301", 3}, {"304", 6},
+		{"400", 2}, {"401", 1}, {"403", 1}, {"404", 4}, {"500", 1},
+	}
+	logLineUserAgents = []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+		"curl/8.4.0",
+		"Go-http-client/1.1",
+		"python-requests/2.31.0",
+	}
+	logLineReferers = []string{
+		"-",
+		"https://www.google.com/",
+		"https://github.com/",
+		"https://example.com/",
+	}
+)
+
+// LogLines handles requests for fake HTTP access log lines in Apache common,
+// nginx combined or JSON format
+func LogLines(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random log lines")
+
+	format := requestFormat(r)
+	switch format {
+	case "", "apache", "nginx", "json":
+	default:
+		RespondWithError(w, r, "format must be apache, nginx or json", http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCount(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	src := randomSource()
+	now := time.Now().UTC()
+	lines := make([]LogLine, count)
+	for i := range lines {
+		lines[i] = generateLogLine(src, now)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if format == "json" {
+		RespondWithJSON(w, lines, http.StatusOK)
+		log.Println("Successfully served random log lines")
+		return
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		if format == "nginx" {
+			sb.WriteString(line.Combined())
+		} else {
+			sb.WriteString(line.Common())
+		}
+		sb.WriteByte('\n')
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(sb.String())); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
+		return
+	}
+
+	log.Println("Successfully served random log lines")
+}
+
+// generateLogLine builds a random request made within logLineWindow of now
+func generateLogLine(src randSource, now time.Time) LogLine {
+	status, _ := strconv.Atoi(pickWeighted(src, logLineStatuses))
+
+	size := 0
+	if status != http.StatusNoContent && status != http.StatusNotModified {
+		size = 200 + src.Intn(50000)
+	}
+
+	return LogLine{
+		RemoteAddr: fmt.Sprintf("%d.%d.%d.%d", 1+src.Intn(223), src.Intn(256), src.Intn(256), 1+src.Intn(254)),
+		Time:       now.Add(-time.Duration(src.Int63() % int64(logLineWindow))).Truncate(time.Second),
+		Method:     pickWeighted(src, logLineMethods),
+		Path:       randomLogPath(src),
+		Protocol:   "HTTP/1.1",
+		Status:     status,
+		Bytes:      size,
+		Referer:    logLineReferers[src.Intn(len(logLineReferers))],
+		UserAgent:  logLineUserAgents[src.Intn(len(logLineUserAgents))],
+	}
+}
+
+// randomLogPath builds a request path from the word corpus, sometimes ending
+// in a numeric ID
+func randomLogPath(src randSource) string {
+	segments := 1 + src.Intn(3)
+	parts := make([]string, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		parts = append(parts, loremWords[src.Intn(len(loremWords))])
+	}
+	if src.Intn(3) == 0 {
+		parts = append(parts, strconv.Itoa(1+src.Intn(10000)))
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Common renders the line in the Apache common log format
+func (l LogLine) Common() string {
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+		l.RemoteAddr, l.Time.Format(apacheTimeLayout), l.Method, l.Path, l.Protocol, l.Status, l.size())
+}
+
+// Combined renders the line in the nginx (combined) log format
+func (l LogLine) Combined() string {
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %d "%s" "%s"`,
+		l.RemoteAddr, l.Time.Format(apacheTimeLayout), l.Method, l.Path, l.Protocol, l.Status, l.Bytes, l.Referer, l.UserAgent)
+}
+
+// size is the response size as the common log format writes it, "-" for none
+func (l LogLine) size() string {
+	if l.Bytes == 0 {
+		return "-"
+	}
+	return strconv.Itoa(l.Bytes)
+}
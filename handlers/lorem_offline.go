@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultOfflineMaxWords caps the words in one offline document, whatever
+// the requested counts, unless OFFLINE_MAX_WORDS says otherwise. The built-in
+// paragraph lengths and count limits stay well below it; it is a backstop
+// for wide OFFLINE_PARAGRAPH_SENTENCES ranges.
+const defaultOfflineMaxWords = 10000
+
+// offlineMaxWords returns OFFLINE_MAX_WORDS, using the default for values
+// below 1
+func offlineMaxWords() int {
+	max := envInt("OFFLINE_MAX_WORDS", defaultOfflineMaxWords)
+	if max < 1 {
+		log.Printf("Invalid OFFLINE_MAX_WORDS=%d, using default %d", max, defaultOfflineMaxWords)
+		return defaultOfflineMaxWords
+	}
+	return max
+}
+
+// wordBudget tracks how many more words an offline generator may produce
+type wordBudget struct {
+	max       int
+	left      int
+	truncated bool
+}
+
+// newWordBudget starts a budget of OFFLINE_MAX_WORDS words
+func newWordBudget() *wordBudget {
+	max := offlineMaxWords()
+	return &wordBudget{max: max, left: max}
+}
+
+// fit charges sentence to the budget, cutting it short with a full stop if
+// it does not fit. It returns false once the budget is spent, after which
+// the sentence is empty and generation should stop.
+func (b *wordBudget) fit(sentence string) (string, bool) {
+	words := strings.Fields(sentence)
+	if len(words) <= b.left {
+		b.left -= len(words)
+		return sentence, true
+	}
+	b.truncated = true
+	if b.left == 0 {
+		return "", false
+	}
+	cut := strings.TrimRight(strings.Join(words[:b.left], " "), ",;") + "."
+	b.left = 0
+	return cut, false
+}
+
+// warnIfTruncated logs when what was cut short by the budget
+func (b *wordBudget) warnIfTruncated(what string) {
+	if b.truncated {
+		log.Printf("WARN: offline %s truncated at OFFLINE_MAX_WORDS=%d words", what, b.max)
+	}
+}
+
+// sentenceRange is the inclusive number of sentences in a paragraph
+type sentenceRange struct {
+	Min int
+	Max int
+}
+
+// defaultParagraphSentences maps loripsum.net paragraph lengths to sentence counts
+var defaultParagraphSentences = map[string]sentenceRange{
+	"short":    {2, 4},
+	"medium":   {4, 6},
+	"long":     {6, 9},
+	"verylong": {9, 14},
+}
+
+// offlineParagraphSentences returns the paragraph length map, overridden by
+// OFFLINE_PARAGRAPH_SENTENCES (e.g. "short=1-2,verylong=12-20"). An invalid
+// override is logged and the defaults are used instead.
+func offlineParagraphSentences() map[string]sentenceRange {
+	value := os.Getenv("OFFLINE_PARAGRAPH_SENTENCES")
+	if value == "" {
+		return defaultParagraphSentences
+	}
+	lengths, err := parseParagraphSentences(value)
+	if err != nil {
+		log.Printf("Invalid OFFLINE_PARAGRAPH_SENTENCES=%q, using defaults: %v", value, err)
+		return defaultParagraphSentences
+	}
+	return lengths
+}
+
+// parseParagraphSentences parses and validates a paragraph length override
+func parseParagraphSentences(value string) (map[string]sentenceRange, error) {
+	lengths := make(map[string]sentenceRange, len(defaultParagraphSentences))
+	for k, v := range defaultParagraphSentences {
+		lengths[k] = v
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		name, bounds, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if _, known := defaultParagraphSentences[name]; !ok || !known {
+			return nil, fmt.Errorf("unknown paragraph length in %q", item)
+		}
+		lo, hi, ok := strings.Cut(bounds, "-")
+		if !ok {
+			hi = lo
+		}
+		min, errMin := strconv.Atoi(lo)
+		max, errMax := strconv.Atoi(hi)
+		if errMin != nil || errMax != nil || min < 1 || max < min {
+			return nil, fmt.Errorf("invalid sentence range in %q", item)
+		}
+		lengths[name] = sentenceRange{min, max}
+	}
+	return lengths, nil
+}
+
+// generateOfflineLorem builds loripsum.net-style HTML paragraphs locally
+func generateOfflineLorem(src randSource, params *LoripsumParams) string {
+	paragraphs := params.NumberOfParagraphs
+	switch {
+	case paragraphs == 0:
+		paragraphs = 4
+	case paragraphs < 0:
+		paragraphs = 1
+	case paragraphs > 10:
+		paragraphs = 10
+	}
+
+	lengths := offlineParagraphSentences()
+	sentences, ok := lengths[params.ParagraphLength]
+	if !ok {
+		sentences = lengths["medium"]
+	}
+
+	budget := newWordBudget()
+	var sb strings.Builder
+	for i, more := 0, true; i < paragraphs && more; i++ {
+		n := sentences.Min + src.Intn(sentences.Max-sentences.Min+1)
+		var paragraph string
+		if paragraph, more = loremParagraph(src, n, budget); paragraph != "" {
+			sb.WriteString("<p>" + paragraph + "</p>\n")
+		}
+	}
+	budget.warnIfTruncated("lorem ipsum")
+	return sb.String()
+}
+
+// loremParagraph joins n random sentences, stopping early and returning
+// false once budget is spent
+func loremParagraph(src randSource, n int, budget *wordBudget) (string, bool) {
+	sentences := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		sentence, ok := budget.fit(loremSentence(src))
+		if sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		if !ok {
+			return strings.Join(sentences, " "), false
+		}
+	}
+	return strings.Join(sentences, " "), true
+}
+
+// loremSentence builds a capitalised sentence of 4 to 12 corpus words
+func loremSentence(src randSource) string {
+	words := make([]string, 4+src.Intn(9))
+	for i := range words {
+		words[i] = loremWords[src.Intn(len(loremWords))]
+	}
+	sentence := strings.Join(words, " ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
-	"time"
+	"strings"
+	"unicode/utf8"
 )
 
 type LoripsumParams struct {
@@ -25,35 +26,117 @@ type LoripsumParams struct {
 	Headers            bool   `json:"headers"`
 	AllCaps            bool   `json:"all_caps"`
 	Prude              bool   `json:"prude"`
+	// Offline generates the text locally instead of calling loripsum.net
+	Offline bool `json:"offline"`
 }
 
 func Loripsum(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request for random lorem ipsum")
 
-	// Check method
-	if r.Method != http.MethodPost && r.Method != http.MethodOptions {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Parse request body
+	params := &LoripsumParams{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		RespondWithError(w, r, "Invalid request body: "+describeJSONError(err), jsonErrorStatus(err))
 		return
 	}
+	debugf("Loripsum params: %+v", *params)
 
-	// Handle CORS preflight
-	if r.Method == http.MethodOptions {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.WriteHeader(http.StatusOK)
+	format := requestFormat(r)
+	switch format {
+	case "", "html", "plain":
+	default:
+		RespondWithError(w, r, "format must be html or plain", http.StatusBadRequest)
 		return
 	}
 
-	// Parse request body
-	params := &LoripsumParams{}
-	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	// Generate locally when requested or seeded, since loripsum.net cannot
+	// reproduce its output; otherwise fetch from the upstream
+	var content []byte
+	if seed := requestSeed(r); seed != "" {
+		content = []byte(generateOfflineLorem(newLockedRand(seedValue(seed)), params))
+	} else if params.Offline {
+		content = []byte(generateOfflineLorem(randomSource(), params))
+	} else {
+		// Construct API URL
+		u, err := url.Parse(upstreamURL("LORIPSUM_URL", defaultLoripsumURL))
+		if err != nil {
+			log.Printf("Error parsing upstream URL: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		u.Path = buildLoripsumPath(params)
+
+		// Set up context with timeout
+		ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(loripsumTimeoutEnv))
+		defer cancel()
+
+		debugf("Lorem ipsum upstream URL: %s", u)
+
+		// Fetch lorem ipsum, falling back to the offline generator once the
+		// retries are exhausted when LORIPSUM_FALLBACK=true
+		resp, err := fetchUpstreamShared(ctx, u.String())
+		if err != nil && os.Getenv("LORIPSUM_FALLBACK") == "true" {
+			log.Printf("WARN: lorem ipsum upstream failed, serving offline fallback: %v", err)
+			fallbackServed.Inc()
+			w.Header().Set("X-Fallback", "true")
+			content = []byte(generateOfflineLorem(randomSource(), params))
+		} else if err != nil {
+			respondUpstreamError(w, err, "lorem ipsum")
+			return
+		} else {
+			setUpstreamDuration(w, resp.Duration)
+			content = resp.Body
+		}
+	}
+
+	// Set headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// Report size statistics alongside the HTML when requested
+	if r.URL.Query().Get("with_stats") == "true" {
+		RespondWithJSON(w, loremStats(string(content)), http.StatusOK)
+		log.Println("Successfully served random lorem ipsum with stats")
+		return
+	}
+
+	// Write response body to client, as text or HTML, compressed if asked for
+	if format == "plain" {
+		content = []byte(strings.TrimSpace(stripHTML(string(content))) + "\n")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if err := writeBody(w, r, content); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
 		return
 	}
 
-	// Construct API URL
+	log.Println("Successfully served random lorem ipsum")
+}
+
+// LoremStats is the JSON response for lorem ipsum requested with stats
+type LoremStats struct {
+	HTML           string `json:"html"`
+	WordCount      int    `json:"word_count"`
+	CharCount      int    `json:"char_count"`
+	ParagraphCount int    `json:"paragraph_count"`
+}
+
+// loremStats measures the visible text of lorem ipsum HTML
+func loremStats(content string) LoremStats {
+	text := strings.TrimSpace(stripHTML(content))
+	return LoremStats{
+		HTML:           content,
+		WordCount:      len(strings.Fields(text)),
+		CharCount:      utf8.RuneCountInString(text),
+		ParagraphCount: strings.Count(content, "<p>"),
+	}
+}
+
+// buildLoripsumPath converts the request parameters into a loripsum.net API path
+func buildLoripsumPath(params *LoripsumParams) string {
 	p := "api"
 	if params.NumberOfParagraphs != 0 {
 		if params.NumberOfParagraphs < 0 {
@@ -109,48 +192,5 @@ func Loripsum(w http.ResponseWriter, r *http.Request) {
 		p = path.Join(p, "prude")
 	}
 
-	u, _ := url.Parse("https://loripsum.net")
-	u.Path = p
-
-	// Set up context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	// Send request
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error fetching lorem ipsum: %v", err)
-		http.Error(w, "Error fetching lorem ipsum", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("API returned non-200 status: %d", resp.StatusCode)
-		http.Error(w, "Upstream API error", http.StatusInternalServerError)
-		return
-	}
-
-	// Set headers
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	// Copy response body to client
-	if _, err := io.Copy(w, resp.Body); err != nil {
-		log.Printf("Error copying response: %v", err)
-		// Cannot write error to client at this point
-		return
-	}
-
-	log.Println("Successfully served random lorem ipsum")
-}
\ No newline at end of file
+	return p
+}
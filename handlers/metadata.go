@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"os"
+)
+
+// defaultEnvironment is reported when DEPLOY_ENV is unset
+const defaultEnvironment = "development"
+
+// Metadata describes the deployment serving a request
+type Metadata struct {
+	Environment string `json:"environment"`
+	Hostname    string `json:"hostname"`
+}
+
+// metadataKey is the context key holding the deployment Metadata
+type metadataKey struct{}
+
+// LoadMetadata resolves the deployment metadata from DEPLOY_ENV and the host
+func LoadMetadata() Metadata {
+	env := os.Getenv("DEPLOY_ENV")
+	if env == "" {
+		env = defaultEnvironment
+	}
+	hostname, _ := os.Hostname()
+	return Metadata{Environment: env, Hostname: hostname}
+}
+
+// WithMetadata returns a copy of ctx carrying md. The server uses it to
+// build the base context every request context derives from.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns the deployment metadata carried by ctx
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
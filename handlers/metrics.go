@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// counter is a monotonically increasing metric exposed on /metrics. It only
+// goes down when ResetMetrics is called.
+type counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+// Inc increments the counter by one
+func (c *counter) Inc() {
+	c.value.Add(1)
+}
+
+// counters lists every registered counter in exposition order
+var counters []*counter
+
+// newCounter creates a counter and registers it for exposition
+func newCounter(name, help string) *counter {
+	c := &counter{name: name, help: help}
+	counters = append(counters, c)
+	return c
+}
+
+var (
+	upstreamRetries = newCounter("upstream_retries_total", "Upstream requests retried after a failure.")
+	fallbackServed  = newCounter("fallback_served_total", "Responses served from a fallback instead of the upstream.")
+	cacheHits       = newCounter("cache_hits_total", "Responses served from the response cache.")
+	cacheMisses     = newCounter("cache_misses_total", "Cacheable requests not found in the response cache.")
+)
+
+// ResetMetrics sets every counter back to zero, returning the values they
+// held. Each counter is swapped atomically, so no increment is lost.
+func ResetMetrics() map[string]int64 {
+	previous := make(map[string]int64, len(counters))
+	for _, c := range counters {
+		previous[c.name] = c.value.Swap(0)
+	}
+	return previous
+}
+
+// Metrics exposes the counters and the unique client gauge in the Prometheus
+// text format
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value.Load())
+	}
+	fmt.Fprintf(w, "# HELP unique_clients Distinct client IPs seen since startup, up to UNIQUE_CLIENTS_MAX.\n# TYPE unique_clients gauge\nunique_clients %d\n", uniqueClients.len())
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Middleware wraps an http.Handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with the given middlewares. The first middleware is the
+// outermost, so requests pass through them in the order they are listed.
+func Chain(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// debug controls whether extra request detail is logged. It is off by default
+// and can be flipped at runtime (see SetDebug and ToggleDebug).
+var debug atomic.Bool
+
+// SetDebug turns verbose debug logging on or off
+func SetDebug(on bool) {
+	debug.Store(on)
+}
+
+// ToggleDebug flips verbose debug logging and returns the new state
+func ToggleDebug() bool {
+	for {
+		old := debug.Load()
+		if debug.CompareAndSwap(old, !old) {
+			return !old
+		}
+	}
+}
+
+// DebugEnabled reports whether verbose debug logging is on
+func DebugEnabled() bool {
+	return debug.Load()
+}
+
+// ConfigureLogger sets up the standard logger, starting every line with
+// prefix when it is set so instances sharing a log stream can be told apart
+func ConfigureLogger(prefix string) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if prefix != "" {
+		prefix += " "
+	}
+	log.SetPrefix(prefix)
+}
+
+// debugf logs a message only when debug logging is enabled
+func debugf(format string, args ...interface{}) {
+	if debug.Load() {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
+
+// defaultSlowRequestThreshold is how long a request may take before it is
+// reported as slow, unless SLOW_REQUEST_THRESHOLD says otherwise
+const defaultSlowRequestThreshold = time.Second
+
+// statusRecorder captures the status code written by a handler and marks
+// the response with X-Slow if the headers go out after the slow threshold
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	start       time.Time
+	slow        time.Duration
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		if r.slow > 0 && time.Since(r.start) > r.slow {
+			r.Header().Set("X-Slow", "true")
+		}
+	}
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming responses working through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		if !r.wroteHeader {
+			r.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LoggingMiddleware logs each request with its status, duration and trace ID,
+// warning about requests slower than SLOW_REQUEST_THRESHOLD. A threshold of
+// zero or less disables the warning.
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		slow := envDuration("SLOW_REQUEST_THRESHOLD", defaultSlowRequestThreshold)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK, start: start, slow: slow}
+
+		debugf("Request %s %s from %s, query=%v, headers=%v", r.Method, r.URL.Path, r.RemoteAddr, redactQuery(r.URL.Query()), redactHeaders(r.Header))
+
+		next.ServeHTTP(rec, r)
+
+		duration := time.Since(start)
+		if tp, ok := TraceFromContext(r.Context()); ok {
+			log.Printf("%s %s %d %s trace_id=%s", r.Method, r.URL.Path, rec.status, duration, tp.TraceID)
+		} else {
+			log.Printf("%s %s %d %s", r.Method, r.URL.Path, rec.status, duration)
+		}
+		if slow > 0 && duration > slow {
+			log.Printf("WARN: slow request %s %s took %s, over the %s threshold", r.Method, r.URL.Path, duration, slow)
+		}
+	})
+}
+
+// MaxInFlightMiddleware caps the number of requests handled concurrently at
+// n, answering any request over the limit with a 503. A limit of zero or
+// less disables the cap.
+func MaxInFlightMiddleware(n int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+		sem := make(chan struct{}, n)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				RespondWithError(w, r, "Server is at capacity, try again shortly", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// defaultMaxBodyBytes caps request bodies on routes without their own limit
+const defaultMaxBodyBytes = 1 << 20
+
+// BodyLimitMiddleware rejects request bodies larger than limit bytes with a
+// 413, falling back to MAX_BODY_BYTES when limit is zero. Bodies that declare
+// their length are rejected up front; others are cut off while being read.
+func BodyLimitMiddleware(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			max := limit
+			if max <= 0 {
+				max = int64(envInt("MAX_BODY_BYTES", defaultMaxBodyBytes))
+			}
+			if r.ContentLength > max {
+				RespondWithError(w, r, fmt.Sprintf("Request body exceeds %d bytes", max), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// defaultDebugBodiesMax caps how much of each body DebugBodiesMiddleware logs
+const defaultDebugBodiesMax = 1024
+
+// redactedHeaders carry credentials and are never logged
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key"}
+
+// redactedQueryParams carry credentials and are never logged
+var redactedQueryParams = []string{"api_key"}
+
+// bodyRecorder captures the first max bytes of a response body and counts
+// the rest
+type bodyRecorder struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	max     int
+	written int64
+}
+
+func (r *bodyRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *bodyRecorder) Write(p []byte) (int, error) {
+	if room := r.max - r.body.Len(); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		r.body.Write(p[:room])
+	}
+	r.written += int64(len(p))
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming responses working through the recorder
+func (r *bodyRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *bodyRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// DebugBodiesMiddleware logs request and response bodies, truncated to
+// DEBUG_BODIES_MAX bytes, when DEBUG_BODIES=true. Only the logged part of
+// the request body is read up front; the handler still sees all of it, under
+// its route's body limit. Credential headers are redacted.
+func DebugBodiesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if os.Getenv("DEBUG_BODIES") != "true" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		max := envInt("DEBUG_BODIES_MAX", defaultDebugBodiesMax)
+		if max < 0 {
+			max = 0
+		}
+
+		var head []byte
+		if r.Body != nil {
+			// Read one byte past max to tell whether the body was cut
+			var err error
+			head, err = io.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+			if err != nil {
+				RespondWithError(w, r, "Error reading request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+		}
+		log.Printf("Request body %s %s headers=%v: %s", r.Method, r.URL.Path, redactHeaders(r.Header), truncateBody(head, r.ContentLength, max))
+
+		rec := &bodyRecorder{ResponseWriter: w, status: http.StatusOK, max: max}
+		next.ServeHTTP(rec, r)
+
+		log.Printf("Response body %s %s %d: %s", r.Method, r.URL.Path, rec.status, truncateBody(rec.body.Bytes(), rec.written, max))
+	})
+}
+
+// redactHeaders returns a copy of h with credential headers masked
+func redactHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range redactedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, "[REDACTED]")
+		}
+	}
+	return h
+}
+
+// redactQuery returns a copy of q with credential parameters masked
+func redactQuery(q url.Values) url.Values {
+	q = maps.Clone(q)
+	for _, name := range redactedQueryParams {
+		if q.Has(name) {
+			q.Set(name, "[REDACTED]")
+		}
+	}
+	return q
+}
+
+// truncateBody quotes up to max bytes of head, the start of a body of total
+// bytes, noting how much was cut. A negative total means the length is unknown.
+func truncateBody(head []byte, total int64, max int) string {
+	max = min(max, len(head))
+	if len(head) <= max && total <= int64(max) {
+		return strconv.Quote(string(head))
+	}
+	if total < int64(len(head)) {
+		return fmt.Sprintf("%q... (truncated)", head[:max])
+	}
+	return fmt.Sprintf("%q... (%d bytes truncated)", head[:max], total-int64(max))
+}
+
+// Default limits enforced by URLLengthMiddleware
+const (
+	defaultMaxURLLength        = 8192
+	defaultMaxQueryParamLength = 2048
+)
+
+// URLLengthMiddleware rejects requests whose URL is longer than
+// MAX_URL_LENGTH with a 414, and those with any query parameter value longer
+// than MAX_QUERY_PARAM_LENGTH with a 400. A limit of zero or less disables
+// the check.
+func URLLengthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if max := envInt("MAX_URL_LENGTH", defaultMaxURLLength); max > 0 && len(r.URL.RequestURI()) > max {
+			RespondWithError(w, r, fmt.Sprintf("URL must be at most %d characters", max), http.StatusRequestURITooLong)
+			return
+		}
+		if max := envInt("MAX_QUERY_PARAM_LENGTH", defaultMaxQueryParamLength); max > 0 {
+			for name, values := range r.URL.Query() {
+				for _, value := range values {
+					if len(value) > max {
+						RespondWithError(w, r, fmt.Sprintf("%s must be at most %d characters", name, max), http.StatusBadRequest)
+						return
+					}
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultReferrerPolicy is sent unless REFERRER_POLICY says otherwise
+const defaultReferrerPolicy = "no-referrer"
+
+// securityHeaders returns the hardening headers to set on every response:
+// none when SECURITY_HEADERS=false, otherwise nosniff, frame denial and the
+// REFERRER_POLICY
+func securityHeaders() map[string]string {
+	if os.Getenv("SECURITY_HEADERS") == "false" {
+		return nil
+	}
+	policy := os.Getenv("REFERRER_POLICY")
+	if policy == "" {
+		policy = defaultReferrerPolicy
+	}
+	return map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        policy,
+	}
+}
+
+// SecurityHeadersMiddleware sets headers on every response before the
+// handler runs. An empty set leaves responses untouched.
+func SecurityHeadersMiddleware(headers map[string]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	// defaultPaginationTotal is the size of the full set when ?total= is absent
+	defaultPaginationTotal = 1000
+	// defaultPaginationMaxTotal bounds the full set a client can page through
+	defaultPaginationMaxTotal = 10000
+)
+
+// Pagination describes one page of a bounded, deterministic result set
+type Pagination struct {
+	Page     int  `json:"page"`
+	PageSize int  `json:"page_size"`
+	Total    int  `json:"total"`
+	NextPage *int `json:"next_page"`
+}
+
+// Offset is the index of the first item on the page
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Len is the number of items on the page, which is short on the last page
+func (p Pagination) Len() int {
+	if n := p.Total - p.Offset(); n < p.PageSize {
+		return n
+	}
+	return p.PageSize
+}
+
+// parsePagination reads ?page=, ?page_size= and ?total=, bounding the total
+// by PAGINATION_MAX_TOTAL and rejecting pages past the end of the set
+func parsePagination(r *http.Request, maxPageSize int) (Pagination, error) {
+	var p Pagination
+	var err error
+
+	if p.Page, err = queryInt(r, "page", 1); err != nil {
+		return p, err
+	}
+	if p.PageSize, err = queryInt(r, "page_size", maxPageSize); err != nil {
+		return p, err
+	}
+	if p.Total, err = queryInt(r, "total", defaultPaginationTotal); err != nil {
+		return p, err
+	}
+
+	maxTotal := envInt("PAGINATION_MAX_TOTAL", defaultPaginationMaxTotal)
+	switch {
+	case p.PageSize < 1 || p.PageSize > maxPageSize:
+		return p, fmt.Errorf("page_size must be between 1 and %d", maxPageSize)
+	case p.Total < 1 || p.Total > maxTotal:
+		return p, fmt.Errorf("total must be between 1 and %d", maxTotal)
+	}
+	// Compare page counts rather than offsets, which a huge page would overflow
+	if pages := (p.Total + p.PageSize - 1) / p.PageSize; p.Page < 1 || p.Page > pages {
+		return p, fmt.Errorf("page must be between 1 and %d", pages)
+	}
+
+	if p.Offset()+p.PageSize < p.Total {
+		next := p.Page + 1
+		p.NextPage = &next
+	}
+	return p, nil
+}
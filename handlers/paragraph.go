@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Bounds and default for ?sentences on /random-paragraph
+const (
+	defaultParagraphSentenceCount = 5
+	maxParagraphSentenceCount     = 50
+)
+
+// paragraphEndings are picked from to end each sentence, weighted towards
+// full stops
+var paragraphEndings = []string{".", ".", ".", ".", "?", "!"}
+
+// paragraphConjunctions join the clauses of compound sentences
+var paragraphConjunctions = []string{"and", "but", "so", "yet", "while"}
+
+// ParagraphResponse is the response body for the random paragraph endpoint
+type ParagraphResponse struct {
+	Paragraph string `json:"paragraph"`
+}
+
+// RandomParagraph handles requests for a single paragraph of varied
+// sentences built from the offline corpus
+func RandomParagraph(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random paragraph")
+
+	n, err := queryInt(r, "sentences", defaultParagraphSentenceCount)
+	if err != nil || n < 1 || n > maxParagraphSentenceCount {
+		RespondWithError(w, r, fmt.Sprintf("sentences must be between 1 and %d", maxParagraphSentenceCount), http.StatusBadRequest)
+		return
+	}
+
+	// Seeded requests get the same paragraph every time
+	src := randomSource()
+	if seed := requestSeed(r); seed != "" {
+		src = newLockedRand(seedValue(seed))
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, ParagraphResponse{Paragraph: generateParagraph(src, n)}, http.StatusOK)
+
+	log.Println("Successfully served random paragraph")
+}
+
+// generateParagraph joins n sentences of varied structure and punctuation,
+// within OFFLINE_MAX_WORDS
+func generateParagraph(src randSource, n int) string {
+	budget := newWordBudget()
+	sentences := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		sentence, ok := budget.fit(variedSentence(src))
+		if sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		if !ok {
+			break
+		}
+	}
+	budget.warnIfTruncated("paragraph")
+	return strings.Join(sentences, " ")
+}
+
+// variedSentence builds a simple, compound or semicolon-joined sentence
+// ending in a full stop, question mark or exclamation mark. Only the end of
+// the sentence carries terminal punctuation.
+func variedSentence(src randSource) string {
+	clause := func() string { return poemWords(src, 3+src.Intn(5)) }
+
+	var s string
+	switch src.Intn(4) {
+	case 0:
+		s = poemWords(src, 4+src.Intn(9))
+	case 1:
+		s = clause() + ", " + paragraphConjunctions[src.Intn(len(paragraphConjunctions))] + " " + clause()
+	case 2:
+		s = clause() + "; " + clause()
+	default:
+		// An introductory phrase set off by a comma
+		s = poemWords(src, 1+src.Intn(2)) + ", " + clause()
+	}
+	return capitalize(s) + paragraphEndings[src.Intn(len(paragraphEndings))]
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+)
+
+const (
+	passwordUpper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordLower   = "abcdefghijklmnopqrstuvwxyz"
+	passwordDigits  = "0123456789"
+	passwordSymbols = "!@#$%^&*()-_=+[]{}<>?"
+
+	maxPasswordLength = 256
+	// maxPasswordCount is below MAX_COUNT as passwords can be long
+	maxPasswordCount = 100
+)
+
+// PasswordPolicy describes the minimum number of characters required from
+// each character class. A class with a minimum of zero is not used.
+type PasswordPolicy struct {
+	Length  int
+	Upper   int
+	Lower   int
+	Digits  int
+	Symbols int
+}
+
+// PasswordResponse is the response body for the random password endpoint
+type PasswordResponse struct {
+	Passwords []string `json:"passwords"`
+}
+
+// Password handles requests for locally generated random passwords
+func Password(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random password")
+
+	// Parse policy
+	policy, err := parsePasswordPolicy(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCountMax(r, maxPasswordCount)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Generate passwords
+	passwords := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		password, err := generatePassword(policy)
+		if err != nil {
+			log.Printf("Error generating password: %v", err)
+			RespondWithError(w, r, "Error generating password", http.StatusInternalServerError)
+			return
+		}
+		passwords = append(passwords, password)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, PasswordResponse{Passwords: passwords}, http.StatusOK)
+
+	log.Println("Successfully served random password")
+}
+
+// parsePasswordPolicy reads and validates the password policy query parameters
+func parsePasswordPolicy(r *http.Request) (PasswordPolicy, error) {
+	policy := PasswordPolicy{}
+	params := []struct {
+		name  string
+		def   int
+		value *int
+	}{
+		{"length", 16, &policy.Length},
+		{"upper", 1, &policy.Upper},
+		{"lower", 1, &policy.Lower},
+		{"digits", 1, &policy.Digits},
+		{"symbols", 0, &policy.Symbols},
+	}
+	for _, p := range params {
+		n, err := queryInt(r, p.name, p.def)
+		if err != nil {
+			return policy, err
+		}
+		if n < 0 {
+			return policy, fmt.Errorf("%s must not be negative", p.name)
+		}
+		*p.value = n
+	}
+
+	required := policy.Upper + policy.Lower + policy.Digits + policy.Symbols
+	if required == 0 {
+		return policy, fmt.Errorf("at least one character class must be required")
+	}
+	if policy.Length > maxPasswordLength {
+		return policy, fmt.Errorf("length must be at most %d", maxPasswordLength)
+	}
+	if policy.Length < required {
+		return policy, fmt.Errorf("length %d is shorter than the %d required characters", policy.Length, required)
+	}
+	return policy, nil
+}
+
+// generatePassword builds a password satisfying the policy using crypto/rand
+func generatePassword(policy PasswordPolicy) (string, error) {
+	classes := []struct {
+		chars string
+		min   int
+	}{
+		{passwordUpper, policy.Upper},
+		{passwordLower, policy.Lower},
+		{passwordDigits, policy.Digits},
+		{passwordSymbols, policy.Symbols},
+	}
+
+	password := make([]byte, 0, policy.Length)
+	pool := ""
+	for _, class := range classes {
+		if class.min == 0 {
+			continue
+		}
+		pool += class.chars
+		for i := 0; i < class.min; i++ {
+			c, err := randomChar(class.chars)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+
+	// Fill the remainder from every enabled class
+	for len(password) < policy.Length {
+		c, err := randomChar(pool)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Shuffle so required characters are not grouped at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := cryptoIntn(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+// randomChar picks a random byte from chars using crypto/rand
+func randomChar(chars string) (byte, error) {
+	i, err := cryptoIntn(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// cryptoIntn returns a uniform random int in [0, n) using crypto/rand
+func cryptoIntn(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Shape of generated poems
+const (
+	minPoemStanzas    = 2
+	maxPoemStanzas    = 4
+	poemStanzaLines   = 4
+	minPoemLineWords  = 3
+	maxPoemLineWords  = 7
+	poemTitleMaxWords = 3
+)
+
+// Poem is a poem split into its title and stanzas of lines
+type Poem struct {
+	Title   string     `json:"title"`
+	Stanzas [][]string `json:"stanzas"`
+}
+
+// RandomPoem handles requests for a random poem as text, HTML or JSON
+func RandomPoem(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random poem")
+
+	format := requestFormat(r)
+	switch format {
+	case "", "text", "html", "json":
+	default:
+		RespondWithError(w, r, "format must be text, html or json", http.StatusBadRequest)
+		return
+	}
+
+	poem := parsePoem(generatePoem(randomSource()))
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	var body string
+	switch format {
+	case "json":
+		RespondWithJSON(w, poem, http.StatusOK)
+		log.Println("Successfully served random poem")
+		return
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		body = poem.HTML()
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body = poem.Text()
+	}
+
+	if _, err := io.WriteString(w, body); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
+		return
+	}
+
+	log.Println("Successfully served random poem")
+}
+
+// generatePoem writes a poem as plain text: the title, then stanzas of
+// lines, each block separated by a blank line
+func generatePoem(src randSource) string {
+	blocks := []string{capitalize(poemWords(src, 1+src.Intn(poemTitleMaxWords)))}
+	for i := minPoemStanzas + src.Intn(maxPoemStanzas-minPoemStanzas+1); i > 0; i-- {
+		lines := make([]string, poemStanzaLines)
+		for j := range lines {
+			lines[j] = capitalize(poemWords(src, minPoemLineWords+src.Intn(maxPoemLineWords-minPoemLineWords+1)))
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// parsePoem splits plain-text poem into its title, the first block, and
+// stanzas, the blank-line separated blocks after it
+func parsePoem(text string) Poem {
+	var poem Poem
+	for _, block := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		switch {
+		case len(lines) == 0:
+		case poem.Title == "":
+			poem.Title = strings.Join(lines, " ")
+		default:
+			poem.Stanzas = append(poem.Stanzas, lines)
+		}
+	}
+	return poem
+}
+
+// Text renders the poem as plain text
+func (p Poem) Text() string {
+	blocks := []string{p.Title}
+	for _, stanza := range p.Stanzas {
+		blocks = append(blocks, strings.Join(stanza, "\n"))
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// HTML renders the poem with its title as a heading and each stanza as a
+// paragraph
+func (p Poem) HTML() string {
+	var sb strings.Builder
+	sb.WriteString("<h1>" + html.EscapeString(p.Title) + "</h1>\n")
+	for _, stanza := range p.Stanzas {
+		lines := make([]string, len(stanza))
+		for i, line := range stanza {
+			lines[i] = html.EscapeString(line)
+		}
+		sb.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return sb.String()
+}
+
+// poemWords joins n random corpus words
+func poemWords(src randSource, n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = loremWords[src.Intn(len(loremWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+// capitalize upper-cases the first letter of s, which is ASCII
+func capitalize(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
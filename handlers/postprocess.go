@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// PostProcessor adjusts a generator's response, for example by adding
+// headers, just before the handler writes its status line
+type PostProcessor func(w http.ResponseWriter, r *http.Request)
+
+// PostProcessors run in order on every generator response. It is empty by
+// default; forks can append to it to customize responses without editing
+// each handler.
+var PostProcessors []PostProcessor
+
+// postProcessWriter runs the post-processors once, before the first write
+type postProcessWriter struct {
+	http.ResponseWriter
+	r    *http.Request
+	done bool
+}
+
+func (p *postProcessWriter) run() {
+	if p.done {
+		return
+	}
+	p.done = true
+	for _, hook := range PostProcessors {
+		hook(p.ResponseWriter, p.r)
+	}
+}
+
+func (p *postProcessWriter) WriteHeader(code int) {
+	p.run()
+	p.ResponseWriter.WriteHeader(code)
+}
+
+func (p *postProcessWriter) Write(b []byte) (int, error) {
+	p.run()
+	return p.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the writer
+func (p *postProcessWriter) Flush() {
+	p.run()
+	if f, ok := p.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// postProcess applies PostProcessors to the responses of next
+func postProcess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(PostProcessors) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&postProcessWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// isGenerator reports whether path serves generated test data
+func isGenerator(path string) bool {
+	return strings.HasPrefix(path, generatorPrefix)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Prewarm sends a HEAD request to every configured upstream so that DNS
+// lookups and TLS handshakes are done before the first client request. It
+// returns once every upstream has answered or failed; failures are only
+// logged.
+func Prewarm(ctx context.Context) {
+	urls := []string{
+		upstreamURL("COMMIT_MESSAGE_URL", defaultCommitMessageURL),
+		upstreamURL("LORIPSUM_URL", defaultLoripsumURL),
+	}
+	urls = append(urls, upstreamURLs("USER_URLS", "USER_URL", defaultUserURL)...)
+
+	ctx, cancel := context.WithTimeout(ctx, envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			if err := prewarmUpstream(ctx, u); err != nil {
+				log.Printf("Error prewarming upstream %s: %v", u, err)
+				return
+			}
+			debugf("Prewarmed upstream %s", u)
+		}(u)
+	}
+	wg.Wait()
+}
+
+// prewarmUpstream opens a connection to the upstream at rawURL with a HEAD
+// request, leaving the connection in the shared pool
+func prewarmUpstream(ctx context.Context, rawURL string) error {
+	if err := checkUpstreamAllowed(rawURL); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClientCreator().Do(req)
+	if err != nil {
+		return err
+	}
+	// Draining the body lets the connection be reused
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// randSource is the source of non-cryptographic randomness used by the
+// generators. Tests can swap in a deterministic source with SetRandSeed.
+type randSource interface {
+	Float64() float64
+	Intn(n int) int
+	Int63() int64
+}
+
+// lockedRand is a rand.Rand guarded by a mutex so it is safe for concurrent use
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63()
+}
+
+var (
+	rngMu sync.RWMutex
+	rng   randSource = newLockedRand(time.Now().UnixNano())
+)
+
+// randomSource returns the package random source
+func randomSource() randSource {
+	rngMu.RLock()
+	defer rngMu.RUnlock()
+	return rng
+}
+
+// SetRandSeed replaces the package random source with one seeded with seed,
+// making generator output reproducible
+func SetRandSeed(seed int64) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng = newLockedRand(seed)
+}
+
+// seedValue turns a client-supplied seed into a random source seed: integers
+// are used as they are, anything else is hashed
+func seedValue(seed string) int64 {
+	if n, err := strconv.ParseInt(seed, 10, 64); err == nil {
+		return n
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return int64(h.Sum64())
+}
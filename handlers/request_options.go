@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestOptions are the response options shared by every endpoint
+type requestOptions struct {
+	format string
+	seed   string
+}
+
+// requestOptionsKey is the context key holding a request's requestOptions
+type requestOptionsKey struct{}
+
+// RequestOptionsMiddleware resolves the format and seed query parameters
+// once and stores them in the request context for the handlers to read
+func RequestOptionsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		opts := requestOptions{format: q.Get("format"), seed: q.Get("seed")}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestOptionsKey{}, opts)))
+	})
+}
+
+// FormatFromContext returns the response format resolved for the request
+func FormatFromContext(ctx context.Context) (string, bool) {
+	opts, ok := ctx.Value(requestOptionsKey{}).(requestOptions)
+	return opts.format, ok
+}
+
+// SeedFromContext returns the random seed resolved for the request
+func SeedFromContext(ctx context.Context) (string, bool) {
+	opts, ok := ctx.Value(requestOptionsKey{}).(requestOptions)
+	return opts.seed, ok
+}
+
+// requestFormat returns r's response format, reading the query directly
+// when RequestOptionsMiddleware has not run
+func requestFormat(r *http.Request) string {
+	if format, ok := FormatFromContext(r.Context()); ok {
+		return format
+	}
+	return r.URL.Query().Get("format")
+}
+
+// requestSeed returns r's random seed, reading the query directly when
+// RequestOptionsMiddleware has not run
+func requestSeed(r *http.Request) string {
+	if seed, ok := SeedFromContext(r.Context()); ok {
+		return seed
+	}
+	return r.URL.Query().Get("seed")
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default per-client retry budget
+const (
+	defaultRetryBudget       = 10
+	defaultRetryBudgetRefill = time.Second
+	// maxRetryBudgetClients bounds how many client buckets are tracked at once
+	maxRetryBudgetClients = 10000
+)
+
+// clientKey is the context key holding the client a request is made for
+type clientKey struct{}
+
+// RetryBudgetMiddleware records the client IP in the request context so that
+// upstream retries made on its behalf are charged to its retry budget
+func RetryBudgetMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, clientIP(r))))
+	})
+}
+
+// retryBucket is a token bucket of retries available to one client
+type retryBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// retryBudgets tracks a token bucket per client IP, safe for concurrent use
+type retryBudgets struct {
+	mu      sync.Mutex
+	buckets map[string]*retryBucket
+}
+
+// take spends one retry token for client, refilling one token every refill
+// up to capacity, and reports whether a token was available
+func (b *retryBudgets) take(client string, capacity int, refill time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.buckets == nil {
+		b.buckets = make(map[string]*retryBucket)
+	}
+
+	now := time.Now()
+	bucket, ok := b.buckets[client]
+	if !ok {
+		if len(b.buckets) >= maxRetryBudgetClients {
+			b.prune(now, capacity, refill)
+		}
+		bucket = &retryBucket{tokens: float64(capacity), last: now}
+		b.buckets[client] = bucket
+	}
+
+	if refill > 0 {
+		bucket.tokens += float64(now.Sub(bucket.last)) / float64(refill)
+	}
+	if bucket.tokens > float64(capacity) {
+		bucket.tokens = float64(capacity)
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// prune drops buckets that have refilled completely, as they are
+// indistinguishable from a new client's
+func (b *retryBudgets) prune(now time.Time, capacity int, refill time.Duration) {
+	for client, bucket := range b.buckets {
+		if refill <= 0 || bucket.tokens+float64(now.Sub(bucket.last))/float64(refill) >= float64(capacity) {
+			delete(b.buckets, client)
+		}
+	}
+}
+
+// clientRetryBudgets holds the retry budget of every client
+var clientRetryBudgets retryBudgets
+
+// takeRetryToken reports whether the client behind ctx may retry an upstream
+// request. Requests without a client, or with RETRY_BUDGET set to zero or
+// less, are not limited.
+func takeRetryToken(ctx context.Context) bool {
+	client, ok := ctx.Value(clientKey{}).(string)
+	if !ok {
+		return true
+	}
+	capacity := envInt("RETRY_BUDGET", defaultRetryBudget)
+	if capacity <= 0 {
+		return true
+	}
+	return clientRetryBudgets.take(client, capacity, envDuration("RETRY_BUDGET_REFILL", defaultRetryBudgetRefill))
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Route describes an endpoint served by the API
+type Route struct {
+	Path        string           `json:"path"`
+	Methods     []string         `json:"methods"`
+	Description string           `json:"description"`
+	Handler     http.HandlerFunc `json:"-"`
+	// MaxBodyBytes caps the request body; zero means MAX_BODY_BYTES
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty"`
+}
+
+// RouteListing is the response body for the route index
+type RouteListing struct {
+	Routes []Route `json:"routes"`
+	// Generators names the routes served under /random-<name>
+	Generators []string `json:"generators"`
+}
+
+// Routes returns the table of endpoints served by the API
+func Routes() []Route {
+	return []Route{
+		{"/random-commit-message", []string{http.MethodGet, http.MethodOptions}, "Random commit message", CommitMessage, 0},
+		{"/random-lorem-ipsum", []string{http.MethodPost, http.MethodOptions}, "Random lorem ipsum HTML", Loripsum, 4 << 10},
+		{"/random-user", []string{http.MethodGet, http.MethodOptions}, "Random user profile", User, 0},
+		{"/random-user/select", []string{http.MethodPost, http.MethodOptions}, "Random user profiles shaped by a GraphQL-style field selection", UserSelect, 4 << 10},
+		{"/random-password", []string{http.MethodGet, http.MethodOptions}, "Random password matching a character-class policy", Password, 0},
+		{"/random-choice", []string{http.MethodGet, http.MethodOptions}, "Random pick from a list of optionally weighted options", Choice, 0},
+		{"/validate-card", []string{http.MethodPost, http.MethodOptions}, "Luhn validation and brand detection for a card number", ValidateCard, 1 << 10},
+		{"/random-logline", []string{http.MethodGet, http.MethodOptions}, "Random HTTP access log lines in Apache, nginx or JSON format", LogLines, 0},
+		{"/random-timeseries", []string{http.MethodGet, http.MethodOptions}, "Random time-series data with a flat, rising or falling trend", TimeSeries, 0},
+		{"/random-uuid", []string{http.MethodGet, http.MethodOptions}, "Random version 1, 4 or 7 UUIDs", UUID, 0},
+		{"/random-json-array", []string{http.MethodGet, http.MethodOptions}, "Random JSON array of ints, strings or flat objects", JSONArray, 0},
+		{"/random-xml", []string{http.MethodGet, http.MethodOptions}, "Random well-formed XML document with bounded depth and element count", XML, 0},
+		{"/random-poem", []string{http.MethodGet, http.MethodOptions}, "Random poem as text, HTML or JSON stanzas", RandomPoem, 0},
+		{"/random-flags", []string{http.MethodGet, http.MethodOptions}, "Random feature-flag keys mapped to booleans or rollout percentages", Flags, 0},
+		{"/random-dataset", []string{http.MethodGet, http.MethodOptions}, "Random users, commit messages and lorem ipsum bundled as JSON or a ZIP", RandomDataset, 0},
+		{"/random-paragraph", []string{http.MethodGet, http.MethodOptions}, "Random paragraph of varied sentences, generated offline", RandomParagraph, 0},
+		{"/random-bytes", []string{http.MethodGet, http.MethodOptions}, "Random binary data of the requested size", RandomBytes, 0},
+		{"/random", []string{http.MethodGet, http.MethodOptions}, "Several random data types in one response, such as ?types=user:2,uuid:5", Composite, 0},
+		{"/random-status", []string{http.MethodGet, http.MethodOptions}, "Random HTTP status code, optionally limited to one class", RandomStatus, 0},
+		{"/health", []string{http.MethodGet}, "Service health status", Health, 0},
+		{"/health/stream", []string{http.MethodGet}, "Service health status pushed as Server-Sent Events every ?interval", HealthStream, 0},
+		{"/healthz", []string{http.MethodGet}, "Alias of /health for Kubernetes probes", Health, 0},
+		{"/ready", []string{http.MethodGet}, "Service readiness status", Ready, 0},
+		{"/readyz", []string{http.MethodGet}, "Alias of /ready for Kubernetes probes", Ready, 0},
+		{"/metrics", []string{http.MethodGet}, "Service counters in Prometheus text format", Metrics, 0},
+		{"/admin/cache/flush", []string{http.MethodPost}, "Flush the response caches (requires ADMIN_TOKEN)", AdminCacheFlush, 0},
+		{"/admin/metrics/reset", []string{http.MethodPost}, "Reset the /metrics counters to zero (requires ADMIN_TOKEN)", AdminMetricsReset, 0},
+		{"/admin/health/degrade", []string{http.MethodPost}, "Force /health to report degraded with ?degraded=true, or stop with false (requires ADMIN_TOKEN)", AdminHealthDegrade, 0},
+		{"/debug/echo", []string{http.MethodPost}, "The method, headers, query and body of the request, with credentials redacted", Echo, 64 << 10},
+		{"/_ping", []string{http.MethodGet}, "Liveness check", Ping, 0},
+		{"/routes", []string{http.MethodGet}, "List of available endpoints", RouteIndex, 0},
+		{"/", []string{http.MethodGet}, "Index page listing available endpoints", RouteIndex, 0},
+	}
+}
+
+// Register adds every route to mux, enforcing each route's allowed methods
+// and body limit and applying PostProcessors to the generator routes
+func Register(mux *http.ServeMux) {
+	for _, route := range Routes() {
+		var h http.Handler = route.Handler
+		if isGenerator(route.Path) {
+			h = postProcess(h)
+		}
+		h = BodyLimitMiddleware(route.MaxBodyBytes)(h)
+		h = MethodMiddleware(route.Methods...)(h)
+		if route.Path == "/" {
+			h = catchAllMiddleware(h)
+		}
+		mux.Handle(route.Path, h)
+	}
+}
+
+// catchAllMiddleware answers every path the "/" pattern catches, other than
+// "/" itself, with a 404 before methods are checked, so unknown paths are a
+// 404 whatever the method
+func catchAllMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			RespondWithError(w, r, "No route for "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MethodMiddleware rejects requests whose method is not in methods with a
+// 405 and an Allow header listing the permitted methods. OPTIONS is always
+// permitted and answered here with the Allow and CORS preflight headers, so
+// handlers never see it.
+func MethodMiddleware(methods ...string) Middleware {
+	allowed := append([]string{}, methods...)
+	if !slices.Contains(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	allow := strings.Join(allowed, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Allow", allow)
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				w.Header().Set("Access-Control-Allow-Methods", allow)
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			if slices.Contains(methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Allow", allow)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		})
+	}
+}
+
+// RouteIndex lists every registered route. It also serves as the catch-all
+// "/" pattern, so any other unmatched path is answered with a 404. The
+// listing only changes between builds, so it is marked as last modified at
+// startup and honors If-Modified-Since.
+func RouteIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/routes" {
+		RespondWithError(w, r, "No route for "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+	if checkNotModified(w, r, startTime) {
+		return
+	}
+	RespondWithJSON(w, RouteListing{Routes: Routes(), Generators: GeneratorNames()}, http.StatusOK)
+}
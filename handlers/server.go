@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultShutdownTimeout is how long in-flight requests may drain on shutdown
+const defaultShutdownTimeout = 30 * time.Second
+
+// Shutdown stops srv gracefully, giving in-flight requests up to timeout to
+// finish. Connections still open after that are closed forcibly and an error
+// describing the forced close is returned.
+func Shutdown(srv *http.Server, timeout time.Duration) error {
+	log.Printf("Shutting down, draining connections for up to %s", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		closeErr := srv.Close()
+		if closeErr != nil {
+			log.Printf("Error force-closing connections: %v", closeErr)
+		}
+		return fmt.Errorf("connections still open after the %s shutdown timeout were force-closed: %w", timeout, err)
+	}
+
+	log.Println("Server shut down cleanly")
+	return nil
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultSQLTable is the table SQL output inserts into unless ?table= says otherwise
+const defaultSQLTable = "users"
+
+// sqlIdentifierPattern matches unquoted SQL identifiers, which are safe to
+// interpolate into statements
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// sqlTable returns the requested table name, rejecting anything that is not
+// a plain identifier
+func sqlTable(table string) (string, error) {
+	if table == "" {
+		return defaultSQLTable, nil
+	}
+	if !sqlIdentifierPattern.MatchString(table) {
+		return "", fmt.Errorf("table must be a SQL identifier of letters, digits and underscores")
+	}
+	return table, nil
+}
+
+// sqlQuote renders s as a SQL string literal, doubling single quotes
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// writeSQLInserts renders one INSERT statement per user into table
+func writeSQLInserts(sb *strings.Builder, table string, users []RandomUser) {
+	for _, u := range users {
+		fmt.Fprintf(sb, "INSERT INTO %s (first,last,email) VALUES (%s, %s, %s);\n",
+			table, sqlQuote(u.Name.First), sqlQuote(u.Name.Last), sqlQuote(u.Email))
+	}
+}
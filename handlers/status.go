@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+)
+
+// statusClasses are the status codes /random-status picks from, by class.
+// Codes that must not carry a body, such as 204 and 304, are left out.
+var statusClasses = map[string][]int{
+	"2xx": {http.StatusOK, http.StatusCreated, http.StatusAccepted},
+	"4xx": {
+		http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+		http.StatusConflict, http.StatusGone, http.StatusTeapot, http.StatusUnprocessableEntity,
+		http.StatusTooManyRequests,
+	},
+	"5xx": {
+		http.StatusInternalServerError, http.StatusNotImplemented, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+	},
+}
+
+// StatusResponse is the response body for successful random statuses
+type StatusResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// RandomStatus responds with a random HTTP status, optionally constrained to
+// one class with ?class=2xx|4xx|5xx, to exercise client error handling
+func RandomStatus(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random status")
+
+	var codes []int
+	switch class := r.URL.Query().Get("class"); class {
+	case "":
+		for _, c := range []string{"2xx", "4xx", "5xx"} {
+			codes = append(codes, statusClasses[c]...)
+		}
+	case "2xx", "4xx", "5xx":
+		codes = statusClasses[class]
+	default:
+		// Validation failures must be distinguishable from generated ones
+		w.Header().Set("X-Random-Status", "false")
+		RespondWithError(w, r, "class must be 2xx, 4xx or 5xx", http.StatusBadRequest)
+		return
+	}
+
+	code := codes[randomSource().Intn(len(codes))]
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Random-Status", "true")
+	if code < http.StatusBadRequest {
+		RespondWithJSON(w, StatusResponse{Status: code, Message: http.StatusText(code)}, code)
+	} else {
+		RespondWithError(w, r, "Randomly generated "+http.StatusText(code), code)
+	}
+
+	log.Printf("Successfully served random status %d", code)
+}
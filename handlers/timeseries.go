@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeSeriesPoints = 100
+	maxTimeSeriesPoints     = 10000
+	defaultTimeSeriesMin    = 0
+	defaultTimeSeriesMax    = 100
+
+	// timeSeriesNoise is the noise amplitude as a fraction of the value range
+	timeSeriesNoise = 0.1
+)
+
+// TimeSeriesPoint is a single timestamped value
+type TimeSeriesPoint struct {
+	T time.Time `json:"t"`
+	V float64   `json:"v"`
+}
+
+// TimeSeriesResponse is the response body for the random time series endpoint
+type TimeSeriesResponse struct {
+	Series []TimeSeriesPoint `json:"series"`
+}
+
+// TimeSeriesParams describes the series to generate
+type TimeSeriesParams struct {
+	Points   int
+	Start    time.Time
+	Interval time.Duration
+	Min      float64
+	Max      float64
+	Trend    string
+}
+
+// TimeSeries handles requests for generated time-series data with a flat,
+// rising or falling trend plus noise
+func TimeSeries(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random time series")
+
+	params, err := parseTimeSeriesParams(r, time.Now().UTC())
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, TimeSeriesResponse{Series: generateTimeSeries(randomSource(), params)}, http.StatusOK)
+
+	log.Println("Successfully served random time series")
+}
+
+// parseTimeSeriesParams reads and validates the series query parameters. The
+// series ends at now unless a start is given.
+func parseTimeSeriesParams(r *http.Request, now time.Time) (TimeSeriesParams, error) {
+	q := r.URL.Query()
+	params := TimeSeriesParams{Interval: time.Hour, Trend: "flat"}
+
+	var err error
+	if params.Points, err = queryInt(r, "points", defaultTimeSeriesPoints); err != nil {
+		return params, err
+	}
+	if params.Points < 1 || params.Points > maxTimeSeriesPoints {
+		return params, fmt.Errorf("points must be between 1 and %d", maxTimeSeriesPoints)
+	}
+
+	if raw := q.Get("interval"); raw != "" {
+		if params.Interval, err = time.ParseDuration(raw); err != nil {
+			return params, fmt.Errorf("interval must be a duration such as 1h or 15m")
+		}
+		if params.Interval <= 0 {
+			return params, fmt.Errorf("interval must be positive")
+		}
+	}
+
+	if params.Min, err = queryFloat(r, "min", defaultTimeSeriesMin); err != nil {
+		return params, err
+	}
+	if params.Max, err = queryFloat(r, "max", defaultTimeSeriesMax); err != nil {
+		return params, err
+	}
+	if params.Min >= params.Max {
+		return params, fmt.Errorf("min must be less than max")
+	}
+
+	switch trend := q.Get("trend"); trend {
+	case "":
+	case "flat", "up", "down":
+		params.Trend = trend
+	default:
+		return params, fmt.Errorf("trend must be flat, up or down")
+	}
+
+	if raw := q.Get("start"); raw != "" {
+		if params.Start, err = time.Parse(time.RFC3339, raw); err != nil {
+			return params, fmt.Errorf("start must be an RFC 3339 timestamp")
+		}
+	} else {
+		params.Start = now.Truncate(params.Interval).Add(-time.Duration(params.Points-1) * params.Interval)
+	}
+
+	return params, nil
+}
+
+// generateTimeSeries drifts from one end of the range towards the other
+// according to the trend, adding uniform noise and clamping to [Min, Max]
+func generateTimeSeries(src randSource, params TimeSeriesParams) []TimeSeriesPoint {
+	span := params.Max - params.Min
+	from, to := params.Min+span/2, params.Min+span/2
+	switch params.Trend {
+	case "up":
+		from, to = params.Min+span*timeSeriesNoise, params.Max-span*timeSeriesNoise
+	case "down":
+		from, to = params.Max-span*timeSeriesNoise, params.Min+span*timeSeriesNoise
+	}
+
+	drift := 0.0
+	if params.Points > 1 {
+		drift = (to - from) / float64(params.Points-1)
+	}
+
+	series := make([]TimeSeriesPoint, params.Points)
+	for i := range series {
+		v := from + drift*float64(i) + (src.Float64()*2-1)*span*timeSeriesNoise
+		if v < params.Min {
+			v = params.Min
+		} else if v > params.Max {
+			v = params.Max
+		}
+		series[i] = TimeSeriesPoint{T: params.Start.Add(time.Duration(i) * params.Interval), V: v}
+	}
+	return series
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// traceparentHeader carries W3C trace context between services
+const traceparentHeader = "traceparent"
+
+// TraceParent is a parsed W3C traceparent header,
+// "<version>-<trace-id>-<parent-id>-<flags>"
+type TraceParent struct {
+	TraceID  string
+	ParentID string
+	Flags    string
+}
+
+// traceKey is the context key holding a request's TraceParent
+type traceKey struct{}
+
+// ParseTraceParent parses a version 00 traceparent header. Trace and parent
+// IDs must be lowercase hex and not all zeros.
+func ParseTraceParent(s string) (TraceParent, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 {
+		return TraceParent{}, errors.New("traceparent must have four dash-separated fields")
+	}
+	if parts[0] != "00" {
+		return TraceParent{}, fmt.Errorf("unsupported traceparent version %q", parts[0])
+	}
+	tp := TraceParent{TraceID: parts[1], ParentID: parts[2], Flags: parts[3]}
+	if !isTraceHex(tp.TraceID, 32) {
+		return TraceParent{}, errors.New("trace ID must be 32 lowercase hex digits, not all zero")
+	}
+	if !isTraceHex(tp.ParentID, 16) {
+		return TraceParent{}, errors.New("parent ID must be 16 lowercase hex digits, not all zero")
+	}
+	if len(tp.Flags) != 2 || strings.Trim(tp.Flags, "0123456789abcdef") != "" {
+		return TraceParent{}, errors.New("trace flags must be 2 lowercase hex digits")
+	}
+	return tp, nil
+}
+
+// String formats tp as a traceparent header value
+func (tp TraceParent) String() string {
+	return "00-" + tp.TraceID + "-" + tp.ParentID + "-" + tp.Flags
+}
+
+// isTraceHex reports whether s is n lowercase hex digits, not all zero
+func isTraceHex(s string, n int) bool {
+	return len(s) == n && strings.Trim(s, "0123456789abcdef") == "" && strings.Trim(s, "0") != ""
+}
+
+// randomTraceHex returns n random bytes as lowercase hex
+func randomTraceHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TracingMiddleware continues the trace in the request's traceparent header,
+// starting a new sampled trace when it is absent or invalid. The request is
+// given its own parent ID, which upstream requests are sent with.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tp, err := ParseTraceParent(r.Header.Get(traceparentHeader))
+		if err != nil {
+			if r.Header.Get(traceparentHeader) != "" {
+				debugf("Ignoring invalid traceparent %q: %v", r.Header.Get(traceparentHeader), err)
+			}
+			tp = TraceParent{TraceID: randomTraceHex(16), Flags: "01"}
+		}
+		tp.ParentID = randomTraceHex(8)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), traceKey{}, tp)))
+	})
+}
+
+// TraceFromContext returns the trace context of the request ctx belongs to
+func TraceFromContext(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(traceKey{}).(TraceParent)
+	return tp, ok
+}
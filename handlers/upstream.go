@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default upstream endpoints, overridable through the environment
+const (
+	defaultCommitMessageURL = "https://whatthecommit.com/index.txt"
+	defaultUserURL          = "https://randomuser.me/api"
+	defaultLoripsumURL      = "https://loripsum.net"
+)
+
+// Default retry policy for upstream requests
+const (
+	defaultUpstreamRetries      = 2
+	defaultUpstreamRetryBackoff = 100 * time.Millisecond
+)
+
+// defaultUpstreamTimeout bounds each upstream request unless UPSTREAM_TIMEOUT
+// or the upstream's own timeout variable says otherwise
+const defaultUpstreamTimeout = 5 * time.Second
+
+// Environment variables holding each upstream's timeout
+const (
+	userTimeoutEnv     = "USER_TIMEOUT"
+	loripsumTimeoutEnv = "LORIPSUM_TIMEOUT"
+	commitTimeoutEnv   = "COMMIT_TIMEOUT"
+)
+
+// upstreamTimeout returns the timeout for the upstream configured by key,
+// falling back to UPSTREAM_TIMEOUT
+func upstreamTimeout(key string) time.Duration {
+	return envDuration(key, envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout))
+}
+
+// defaultAllowedHosts are the upstream hosts requests may always be sent to
+var defaultAllowedHosts = []string{"whatthecommit.com", "randomuser.me", "loripsum.net"}
+
+// errUpstreamNotAllowed is returned for upstream URLs outside the allowlist
+var errUpstreamNotAllowed = errors.New("upstream host not allowed")
+
+// Default connection pooling for upstream requests
+const (
+	defaultUpstreamMaxIdleConns        = 100
+	defaultUpstreamMaxIdleConnsPerHost = 20
+	defaultUpstreamIdleConnTimeout     = 90 * time.Second
+)
+
+// NewUpstreamTransport builds the transport for upstream requests, sizing
+// its connection pool from UPSTREAM_MAX_IDLE_CONNS,
+// UPSTREAM_MAX_IDLE_CONNS_PER_HOST and UPSTREAM_IDLE_CONN_TIMEOUT
+func NewUpstreamTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = envInt("UPSTREAM_MAX_IDLE_CONNS", defaultUpstreamMaxIdleConns)
+	t.MaxIdleConnsPerHost = envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", defaultUpstreamMaxIdleConnsPerHost)
+	t.IdleConnTimeout = envDuration("UPSTREAM_IDLE_CONN_TIMEOUT", defaultUpstreamIdleConnTimeout)
+	return t
+}
+
+// upstreamTransport is shared by every upstream client so they share one
+// connection pool
+var upstreamTransport = sync.OnceValue(NewUpstreamTransport)
+
+// httpClientCreator builds the client used for upstream requests. It sets no
+// timeout of its own; callers bound each request with upstreamTimeout.
+var httpClientCreator = func() *http.Client {
+	return &http.Client{Transport: upstreamTransport()}
+}
+
+// upstreamStatusError is returned when an upstream answers with a non-200 status
+type upstreamStatusError struct {
+	StatusCode int
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream returned status %d", e.StatusCode)
+}
+
+// upstreamResponse holds a fully read upstream response
+type upstreamResponse struct {
+	Body   []byte
+	Header http.Header
+	// Duration is the time spent sending the request and reading the body
+	Duration time.Duration
+}
+
+// hopByHopHeaders are meaningful only for a single connection and must not
+// be forwarded (RFC 7230 section 6.1)
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ScrubHopByHop removes hop-by-hop headers from h, including any extra
+// headers named in its Connection header
+func ScrubHopByHop(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// upstreamURL returns the URL configured in the environment variable key, or def
+func upstreamURL(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// upstreamURLs returns the comma-separated URLs configured in listKey, falling
+// back to the single URL from upstreamURL(key, def)
+func upstreamURLs(listKey, key, def string) []string {
+	var urls []string
+	for _, u := range strings.Split(os.Getenv(listKey), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		urls = []string{upstreamURL(key, def)}
+	}
+	return urls
+}
+
+// fetchWithFailover tries each upstream URL in order, moving on to the next
+// once one has failed after its retries
+func fetchWithFailover(ctx context.Context, urls []string) (*upstreamResponse, error) {
+	var err error
+	for i, u := range urls {
+		var resp *upstreamResponse
+		resp, err = fetchUpstreamShared(ctx, u)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if i < len(urls)-1 {
+			log.Printf("Upstream %s failed, failing over: %v", u, err)
+		}
+	}
+	return nil, err
+}
+
+// fetchUpstream performs a GET request against an upstream and reads the
+// body, retrying transport errors and 5xx responses up to UPSTREAM_RETRIES
+// times with a linear backoff of UPSTREAM_RETRY_BACKOFF. Each retry is
+// charged to the client's retry budget and retrying stops once it runs out.
+func fetchUpstream(ctx context.Context, rawURL string) (*upstreamResponse, error) {
+	if err := checkUpstreamAllowed(rawURL); err != nil {
+		log.Printf("Refusing upstream request: %v", err)
+		return nil, err
+	}
+
+	retries := envInt("UPSTREAM_RETRIES", defaultUpstreamRetries)
+	backoff := envDuration("UPSTREAM_RETRY_BACKOFF", defaultUpstreamRetryBackoff)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := fetchUpstreamOnce(ctx, rawURL)
+		if err == nil || attempt >= retries || !isRetryable(ctx, err) {
+			return resp, err
+		}
+		if !takeRetryToken(ctx) {
+			debugf("Retry budget exhausted, not retrying upstream request to %s: %v", rawURL, err)
+			return resp, err
+		}
+
+		upstreamRetries.Inc()
+		debugf("Retrying upstream request to %s after error: %v (attempt %d)", rawURL, err, attempt+1)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff * time.Duration(attempt+1)):
+		}
+	}
+}
+
+// checkUpstreamAllowed rejects URLs whose host is not in the default
+// allowlist or the comma-separated UPSTREAM_ALLOWED_HOSTS, guarding against
+// requests being steered at internal services
+func checkUpstreamAllowed(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parsing upstream URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", errUpstreamNotAllowed, u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, h := range allowedUpstreamHosts() {
+		if h == host {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", errUpstreamNotAllowed, host)
+}
+
+// fetchUpstreamOnce performs a single GET request against an upstream, within
+// the concurrency limit of its host
+func fetchUpstreamOnce(ctx context.Context, rawURL string) (*upstreamResponse, error) {
+	release, err := acquireHost(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if tp, ok := TraceFromContext(ctx); ok {
+		req.Header.Set(traceparentHeader, tp.String())
+	}
+
+	start := time.Now()
+	resp, err := httpClientCreator().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	debugf("Upstream %s responded with status %d, content-length %d", req.URL.Host, resp.StatusCode, resp.ContentLength)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &upstreamStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	// Only end-to-end headers may ever be passed on to clients
+	ScrubHopByHop(resp.Header)
+
+	return &upstreamResponse{Body: body, Header: resp.Header, Duration: time.Since(start)}, nil
+}
+
+// isRetryable reports whether a failed upstream request is worth retrying
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// setUpstreamDuration reports the time spent in upstream calls to the client.
+// Responses served from the cache made no upstream call and report zero.
+func setUpstreamDuration(w http.ResponseWriter, d time.Duration) {
+	if w.Header().Get("X-Cache") == "HIT" {
+		d = 0
+	}
+	w.Header().Set("X-Upstream-Duration", d.String())
+}
+
+// respondUpstreamError logs an upstream failure and writes the matching error
+// response; what names the data being fetched
+func respondUpstreamError(w http.ResponseWriter, err error, what string) {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		log.Printf("API returned non-200 status: %d", statusErr.StatusCode)
+		http.Error(w, "Upstream API error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Error fetching %s: %v", what, err)
+	http.Error(w, "Error fetching "+what, http.StatusInternalServerError)
+}
@@ -1,71 +1,526 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
+const (
+	// maxUserCount bounds how many users can be requested in one batch, in
+	// place of MAX_COUNT since each batch is a single upstream request
+	maxUserCount = 100
+	// defaultUserDownloadMax bounds how many users a download may stream
+	defaultUserDownloadMax = 5000
+	// defaultUserCacheTTL is how long seeded responses are cached
+	defaultUserCacheTTL = 5 * time.Minute
+	// defaultUserCacheMaxEntries bounds the number of cached seeded responses
+	defaultUserCacheMaxEntries = 1000
+)
+
+// userNationalities are the nationalities randomuser.me can generate. The
+// upstream derives names, addresses and phone numbers from the same
+// nationality, so forwarding nat keeps them consistent with each other.
+var userNationalities = []string{
+	"au", "br", "ca", "ch", "de", "dk", "es", "fi", "fr", "gb", "ie",
+	"in", "ir", "mx", "nl", "no", "nz", "rs", "tr", "ua", "us",
+}
+
 func User(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request for random user data")
 
-	// Check method
-	if r.Method != http.MethodGet && r.Method != http.MethodOptions {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Parse output format and batch size
+	format := requestFormat(r)
+	switch format {
+	case "", "json", "vcard", "ndjson", "sql", "yaml", "html":
+	default:
+		RespondWithError(w, r, "format must be json, ndjson, vcard, sql, yaml or html", http.StatusBadRequest)
+		return
+	}
+
+	table := ""
+	if format == "sql" {
+		var err error
+		if table, err = sqlTable(r.URL.Query().Get("table")); err != nil {
+			RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("download") == "true" {
+		streamUserDownload(w, r)
+		return
+	}
+
+	if r.URL.Query().Has("page") {
+		serveUserPage(w, r)
+		return
+	}
+
+	if r.URL.Query().Get("names_only") == "true" {
+		serveUserNames(w, r)
+		return
+	}
+
+	count, err := parseCountMax(r, maxUserCount)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transforms, err := userTransforms(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filters, err := userFilters(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	seed, err := parseSeed(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Handle CORS preflight
-	if r.Method == http.MethodOptions {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.WriteHeader(http.StatusOK)
+	femaleRatio, err := queryFloat(r, "female_ratio", -1)
+	if err != nil || (r.URL.Query().Has("female_ratio") && (femaleRatio < 0 || femaleRatio > 1)) {
+		RespondWithError(w, r, "female_ratio must be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+	if femaleRatio >= 0 && filters.Has("gender") {
+		RespondWithError(w, r, "female_ratio cannot be combined with gender", http.StatusBadRequest)
 		return
 	}
 
 	// Set up context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(userTimeoutEnv))
 	defer cancel()
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://randomuser.me/api", nil)
+	// Fetch user data, serving seeded requests from the cache when possible
+	var resp *upstreamResponse
+	if femaleRatio >= 0 {
+		resp, err = fetchUsersByRatio(ctx, w, count, seed, filters, femaleRatio)
+	} else {
+		resp, err = fetchUserCached(ctx, w, userUpstreamURLs(userQuery(count, seed, filters)), seed != "")
+	}
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		respondUpstreamError(w, err, "user data")
 		return
 	}
 
-	// Send request
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	// In strict mode, fail loudly if the upstream schema has drifted
+	if r.URL.Query().Get("strict") == "true" {
+		if err := checkUserSchema(resp.Body); err != nil {
+			log.Printf("WARN: upstream user schema drift: %v", err)
+			RespondWithError(w, r, "Upstream user data does not match the expected schema: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	body, err := applyUserTransforms(resp.Body, transforms, 0)
+	if errors.Is(err, errUnknownPath) {
+		RespondWithError(w, r, "Cannot project "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		log.Printf("Error fetching user data: %v", err)
-		http.Error(w, "Error fetching user data", http.StatusInternalServerError)
+		log.Printf("Error transforming user data: %v", err)
+		RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
 		return
 	}
-	defer resp.Body.Close()
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("API returned non-200 status: %d", resp.StatusCode)
-		http.Error(w, "Upstream API error", http.StatusInternalServerError)
+	// Set headers
+	setUpstreamDuration(w, resp.Duration)
+
+	if r.URL.Query().Get("avatar_proxy") == "true" {
+		proxyAvatar(ctx, w, r, body)
 		return
 	}
 
-	// Set headers
-	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Copy response body to client
-	if _, err := io.Copy(w, resp.Body); err != nil {
-		log.Printf("Error copying response: %v", err)
+	switch format {
+	case "vcard", "sql", "html":
+		var users RandomUserResponse
+		if err := json.Unmarshal(body, &users); err != nil {
+			log.Printf("Error decoding user data: %v", err)
+			RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+			return
+		}
+		if format == "html" {
+			err = writeUserTable(w, userTable{Users: users.Results})
+			break
+		}
+		var sb strings.Builder
+		if format == "sql" {
+			writeSQLInserts(&sb, table, users.Results)
+			w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+		} else {
+			writeVCards(&sb, users.Results)
+			w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+		}
+		_, err = io.WriteString(w, sb.String())
+	case "ndjson":
+		results, decodeErr := decodeUserResults(body)
+		if decodeErr != nil {
+			log.Printf("Error decoding user data: %v", decodeErr)
+			RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err = writeNDJSON(w, results)
+	default:
+		batch, decodeErr := decodeUserBatch(body)
+		if decodeErr != nil {
+			log.Printf("Error decoding user data: %v", decodeErr)
+			RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+			return
+		}
+		if format == "yaml" {
+			RespondWithYAML(w, batch, http.StatusOK)
+		} else {
+			RespondWithJSON(w, batch, http.StatusOK)
+		}
+	}
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
 		// Cannot write error to client at this point
 		return
 	}
 
 	log.Println("Successfully served random user data")
-}
\ No newline at end of file
+}
+
+// streamUserDownload streams up to USER_DOWNLOAD_MAX users as an NDJSON file
+// attachment, fetching them from the upstream one page at a time so the full
+// set is never held in memory. NDJSON is the only format downloads support.
+func streamUserDownload(w http.ResponseWriter, r *http.Request) {
+	if format := requestFormat(r); format != "" && format != "ndjson" {
+		RespondWithError(w, r, "download supports only format=ndjson", http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCountMax(r, envInt("USER_DOWNLOAD_MAX", defaultUserDownloadMax))
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transforms, err := userTransforms(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filters, err := userFilters(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Large downloads take many upstream pages and outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		debugf("Cannot clear the write deadline for the user download: %v", err)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for sent := 0; sent < count; {
+		page := count - sent
+		if page > maxUserCount {
+			page = maxUserCount
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(userTimeoutEnv))
+		resp, err := fetchWithFailover(ctx, userUpstreamURLs(userQuery(page, "", filters)))
+		cancel()
+
+		var results []json.RawMessage
+		if err == nil {
+			var body []byte
+			if body, err = applyUserTransforms(resp.Body, transforms, sent); err == nil {
+				results, err = decodeUserResults(body)
+			}
+		}
+		if err != nil {
+			if sent == 0 {
+				respondUpstreamError(w, err, "user data")
+				return
+			}
+			// Headers are already sent; the truncated file is all we can offer
+			log.Printf("Error fetching user page after %d users: %v", sent, err)
+			return
+		}
+
+		if sent == 0 {
+			// Only the first page's timing can be reported before streaming starts
+			setUpstreamDuration(w, resp.Duration)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="users.ndjson"`)
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		if len(results) > count-sent {
+			results = results[:count-sent]
+		}
+		if err := writeNDJSON(w, results); err != nil {
+			log.Printf("Error writing response: %v", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(results) == 0 {
+			log.Printf("Upstream returned an empty page after %d users", sent)
+			return
+		}
+		sent += len(results)
+	}
+
+	log.Printf("Successfully streamed %d random users", count)
+}
+
+// UserBatch is the JSON response for a batch of users, shaped the same
+// however many users were requested
+type UserBatch struct {
+	Count   int               `json:"count"`
+	Results []json.RawMessage `json:"results"`
+	// Info is the upstream's metadata, such as the seed it used
+	Info json.RawMessage `json:"info,omitempty"`
+}
+
+// decodeUserBatch re-wraps an upstream response in a UserBatch
+func decodeUserBatch(body []byte) (UserBatch, error) {
+	var envelope struct {
+		Results []json.RawMessage `json:"results"`
+		Info    json.RawMessage   `json:"info"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return UserBatch{}, err
+	}
+	if envelope.Results == nil {
+		envelope.Results = []json.RawMessage{}
+	}
+	return UserBatch{Count: len(envelope.Results), Results: envelope.Results, Info: envelope.Info}, nil
+}
+
+// decodeUserResults extracts the raw user objects from an upstream response
+func decodeUserResults(body []byte) ([]json.RawMessage, error) {
+	var envelope struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Results, nil
+}
+
+// writeNDJSON writes each JSON value on its own line
+func writeNDJSON(w io.Writer, values []json.RawMessage) error {
+	for _, v := range values {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchUserCached fetches user data, caching the response when it is
+// deterministic (seeded) and marking the response with X-Cache. A
+// USER_CACHE_TTL or USER_CACHE_MAX_ENTRIES of zero or less disables the cache.
+func fetchUserCached(ctx context.Context, w http.ResponseWriter, urls []string, cacheable bool) (*upstreamResponse, error) {
+	debugf("User data upstream URLs: %s", strings.Join(urls, ", "))
+	if !cacheable || !userCacheEnabled() {
+		return fetchWithFailover(ctx, urls)
+	}
+	ttl := envDuration("USER_CACHE_TTL", defaultUserCacheTTL)
+	maxEntries := envInt("USER_CACHE_MAX_ENTRIES", defaultUserCacheMaxEntries)
+
+	// The upstream URLs carry every forwarded parameter, so they identify the response
+	key := strings.Join(urls, ",")
+	if resp, ok := userCache.get(key); ok {
+		cacheHits.Inc()
+		w.Header().Set("X-Cache", "HIT")
+		return resp, nil
+	}
+
+	cacheMisses.Inc()
+	resp, err := fetchWithFailover(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	userCache.set(key, resp, ttl, maxEntries)
+	w.Header().Set("X-Cache", "MISS")
+	return resp, nil
+}
+
+// fetchUsersByRatio fetches count users of whom a femaleRatio share are
+// female. Each gender is requested from the upstream separately and the two
+// batches are shuffled together, reproducibly when seeded.
+func fetchUsersByRatio(ctx context.Context, w http.ResponseWriter, count int, seed string, filters url.Values, femaleRatio float64) (*upstreamResponse, error) {
+	females := int(math.Round(float64(count) * femaleRatio))
+	parts := []struct {
+		gender string
+		count  int
+	}{{"female", females}, {"male", count - females}}
+
+	var envelope map[string]json.RawMessage
+	var results []json.RawMessage
+	var duration time.Duration
+	for _, part := range parts {
+		if part.count == 0 {
+			continue
+		}
+		query := userQuery(part.count, seed, filters)
+		query.Set("gender", part.gender)
+		resp, err := fetchUserCached(ctx, w, userUpstreamURLs(query), seed != "")
+		if err != nil {
+			return nil, err
+		}
+		duration += resp.Duration
+
+		if err := json.Unmarshal(resp.Body, &envelope); err != nil {
+			return nil, err
+		}
+		var batch []json.RawMessage
+		if err := json.Unmarshal(envelope["results"], &batch); err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+
+	src := randomSource()
+	if seed != "" {
+		src = newLockedRand(seedValue(seed))
+	}
+	for i := len(results) - 1; i > 0; i-- {
+		j := src.Intn(i + 1)
+		results[i], results[j] = results[j], results[i]
+	}
+
+	var err error
+	if envelope["results"], err = json.Marshal(results); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamResponse{Body: body, Duration: duration}, nil
+}
+
+// userQuery builds the upstream query for count users, optionally seeded so
+// the upstream returns the same users each time and narrowed by filters.
+// Values are only ever sent through url.Values so they are always escaped.
+func userQuery(count int, seed string, filters url.Values) url.Values {
+	params := url.Values{}
+	for k, v := range filters {
+		params[k] = v
+	}
+	if count > 1 {
+		params.Set("results", strconv.Itoa(count))
+	}
+	if seed != "" {
+		params.Set("seed", seed)
+	}
+	return params
+}
+
+// userFilters reads and validates the query parameters that narrow the
+// users the upstream generates
+func userFilters(r *http.Request) (url.Values, error) {
+	filters := url.Values{}
+
+	nat, err := parseNationalities(r)
+	if err != nil {
+		return nil, err
+	}
+	if nat != "" {
+		filters.Set("nat", nat)
+	}
+
+	switch gender := r.URL.Query().Get("gender"); gender {
+	case "":
+	case "male", "female":
+		filters.Set("gender", gender)
+	default:
+		return nil, fmt.Errorf("gender must be male or female")
+	}
+
+	return filters, nil
+}
+
+// parseSeed reads the "seed" query parameter, rejecting control characters
+// so a seed cannot smuggle anything into the upstream request
+func parseSeed(r *http.Request) (string, error) {
+	seed := requestSeed(r)
+	if strings.IndexFunc(seed, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("seed must not contain control characters")
+	}
+	return seed, nil
+}
+
+// parseNationalities reads the comma-separated "nat" query parameter,
+// returning it lowercased once every nationality is known to the upstream
+func parseNationalities(r *http.Request) (string, error) {
+	raw := r.URL.Query().Get("nat")
+	if raw == "" {
+		return "", nil
+	}
+
+	var nats []string
+	for _, nat := range strings.Split(raw, ",") {
+		nat = strings.ToLower(strings.TrimSpace(nat))
+		known := false
+		for _, n := range userNationalities {
+			if n == nat {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", fmt.Errorf("unsupported nationality %q", nat)
+		}
+		nats = append(nats, nat)
+	}
+	return strings.Join(nats, ","), nil
+}
+
+// userUpstreamURLs adds params to each configured upstream URL
+func userUpstreamURLs(params url.Values) []string {
+	urls := upstreamURLs("USER_URLS", "USER_URL", defaultUserURL)
+	if len(params) == 0 {
+		return urls
+	}
+
+	for i, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		q := u.Query()
+		for k, v := range params {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+		urls[i] = u.String()
+	}
+	return urls
+}
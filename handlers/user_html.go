@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// userTableTemplate renders users as an HTML table. html/template escapes
+// every field, so upstream data cannot inject markup.
+var userTableTemplate = template.Must(template.New("users").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Random users</title>
+</head>
+<body>
+<table>
+{{- with .Page}}
+<caption>Page {{.Page}}, {{.PageSize}} per page, of {{.Total}} users</caption>
+{{- end}}
+<thead>
+<tr><th>Name</th><th>Gender</th><th>Email</th><th>Phone</th><th>City</th><th>Country</th><th>Nationality</th></tr>
+</thead>
+<tbody>
+{{- range .Users}}
+<tr><td>{{.Name.First}} {{.Name.Last}}</td><td>{{.Gender}}</td><td>{{.Email}}</td><td>{{.Phone}}</td><td>{{.Location.City}}</td><td>{{.Location.Country}}</td><td>{{.Nat}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- with .NextURL}}
+<p><a href="{{.}}">Next page</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// userTable is the data rendered by userTableTemplate. Page and NextURL are
+// only set in paginated mode.
+type userTable struct {
+	Users   []RandomUser
+	Page    *Pagination
+	NextURL string
+}
+
+// typedUserFormat reports whether format renders users from their typed
+// fields, so transforms that reshape users cannot be applied
+func typedUserFormat(format string) bool {
+	return format == "vcard" || format == "sql" || format == "html"
+}
+
+// writeUserTable renders users as an HTML page holding a table
+func writeUserTable(w http.ResponseWriter, table userTable) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return userTableTemplate.Execute(w, table)
+}
+
+// nextPageURL returns the URL of the page after pagination, carrying over r's
+// query with the seed pinned so the pages belong to the same set
+func nextPageURL(r *http.Request, pagination Pagination, seed string) string {
+	if pagination.NextPage == nil {
+		return ""
+	}
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(*pagination.NextPage))
+	q.Set("seed", seed)
+	return r.URL.Path + "?" + q.Encode()
+}
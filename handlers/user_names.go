@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxUserNameAttempts bounds how many batches are fetched to find enough
+// distinct first names for ?unique=true
+const maxUserNameAttempts = 5
+
+// UserNames is the response for users requested with ?names_only=true
+type UserNames struct {
+	Names []string `json:"names"`
+}
+
+// serveUserNames serves just the first names of count users. With
+// ?unique=true repeated names are dropped and more users fetched, over-fetching
+// to make up for repeats, until count distinct names are found.
+func serveUserNames(w http.ResponseWriter, r *http.Request) {
+	if format := requestFormat(r); format != "" && format != "json" {
+		RespondWithError(w, r, "names_only is only supported for the json format", http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCountMax(r, maxUserCount)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filters, err := userFilters(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	seed, err := parseSeed(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	unique := r.URL.Query().Get("unique") == "true"
+
+	// Set up context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(userTimeoutEnv))
+	defer cancel()
+
+	names := make([]string, 0, count)
+	seen := map[string]bool{}
+	var duration time.Duration
+	for attempt := 1; len(names) < count; attempt++ {
+		if attempt > maxUserNameAttempts {
+			RespondWithError(w, r, fmt.Sprintf("Found only %d unique first names after %d attempts", len(names), maxUserNameAttempts), http.StatusBadGateway)
+			return
+		}
+
+		batch := count - len(names)
+		if unique {
+			batch = min(2*batch, maxUserCount)
+		}
+		// Vary the seed between attempts so a seeded upstream does not return
+		// the same users again
+		attemptSeed := seed
+		if seed != "" && attempt > 1 {
+			attemptSeed = fmt.Sprintf("%s-%d", seed, attempt)
+		}
+
+		resp, err := fetchUserCached(ctx, w, userUpstreamURLs(userQuery(batch, attemptSeed, filters)), seed != "")
+		if err != nil {
+			respondUpstreamError(w, err, "user data")
+			return
+		}
+		duration += resp.Duration
+
+		var users RandomUserResponse
+		if err := json.Unmarshal(resp.Body, &users); err != nil {
+			log.Printf("Error decoding user data: %v", err)
+			RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+			return
+		}
+		for _, user := range users.Results {
+			key := strings.ToLower(user.Name.First)
+			if unique && seen[key] {
+				continue
+			}
+			seen[key] = true
+			names = append(names, user.Name.First)
+			if len(names) == count {
+				break
+			}
+		}
+		debugf("Collected %d of %d first names (attempt %d)", len(names), count, attempt)
+	}
+
+	setUpstreamDuration(w, duration)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, UserNames{Names: names}, http.StatusOK)
+
+	log.Printf("Successfully served %d random first names", len(names))
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// UserPage is one page of a seeded, paginated user set
+type UserPage struct {
+	Results []json.RawMessage `json:"results"`
+	Seed    string            `json:"seed"`
+	Pagination
+}
+
+// serveUserPage serves one page of a bounded user set. randomuser.me pages
+// deterministically under a seed, so pages never overlap and together cover
+// the whole set; a seed is generated when the client does not supply one.
+// Only pages requested with the client's own seed are cached, since nobody
+// can ask for a generated seed's first page again.
+func serveUserPage(w http.ResponseWriter, r *http.Request) {
+	pagination, err := parsePagination(r, maxUserCount)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transforms, err := userTransforms(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filters, err := userFilters(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	seed, err := parseSeed(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheable := seed != ""
+	if !cacheable {
+		seed = strconv.FormatInt(randomSource().Int63(), 36)
+	}
+
+	// Set up context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(userTimeoutEnv))
+	defer cancel()
+
+	params := userQuery(pagination.PageSize, seed, filters)
+	params.Set("page", strconv.Itoa(pagination.Page))
+	// userQuery leaves out results=1, but paging needs the page size explicitly
+	params.Set("results", strconv.Itoa(pagination.PageSize))
+
+	resp, err := fetchUserCached(ctx, w, userUpstreamURLs(params), cacheable)
+	if err != nil {
+		respondUpstreamError(w, err, "user data")
+		return
+	}
+
+	body, err := applyUserTransforms(resp.Body, transforms, pagination.Offset())
+	if err != nil {
+		log.Printf("Error transforming user data: %v", err)
+		RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+		return
+	}
+
+	results, err := decodeUserResults(body)
+	if err != nil {
+		log.Printf("Error decoding user data: %v", err)
+		RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+		return
+	}
+	if len(results) > pagination.Len() {
+		results = results[:pagination.Len()]
+	}
+
+	setUpstreamDuration(w, resp.Duration)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if requestFormat(r) == "html" {
+		users := make([]RandomUser, len(results))
+		for i, raw := range results {
+			if err := json.Unmarshal(raw, &users[i]); err != nil {
+				log.Printf("Error decoding user data: %v", err)
+				RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+				return
+			}
+		}
+		table := userTable{Users: users, Page: &pagination, NextURL: nextPageURL(r, pagination, seed)}
+		if err := writeUserTable(w, table); err != nil {
+			log.Printf("Error writing response: %v", err)
+			// Cannot write error to client at this point
+			return
+		}
+		log.Println("Successfully served random user page as HTML")
+		return
+	}
+
+	RespondWithJSON(w, UserPage{Results: results, Seed: seed, Pagination: pagination}, http.StatusOK)
+
+	log.Println("Successfully served random user page")
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// UserSelection is the request body for selecting user fields. Select maps
+// field names to true for the whole value or to a nested selection, as in
+// {"name": {"first": true}, "email": true}.
+type UserSelection struct {
+	Count  int                    `json:"count"`
+	Select map[string]interface{} `json:"select"`
+}
+
+// UserSelect handles requests for users shaped by a GraphQL-style selection
+// of their fields
+func UserSelect(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for selected user fields")
+
+	// Parse request body
+	var sel UserSelection
+	if err := json.NewDecoder(r.Body).Decode(&sel); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		RespondWithError(w, r, "Invalid request body: "+describeJSONError(err), jsonErrorStatus(err))
+		return
+	}
+	if sel.Count == 0 {
+		sel.Count = 1
+	}
+	if sel.Count < 1 || sel.Count > maxUserCount {
+		RespondWithError(w, r, fmt.Sprintf("count must be between 1 and %d", maxUserCount), http.StatusBadRequest)
+		return
+	}
+	if len(sel.Select) == 0 {
+		RespondWithError(w, r, "select must name at least one field", http.StatusBadRequest)
+		return
+	}
+	paths, err := selectionPaths(sel.Select, reflect.TypeOf(RandomUser{}), nil)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Set up context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), upstreamTimeout(userTimeoutEnv))
+	defer cancel()
+
+	// Fetch user data
+	resp, err := fetchWithFailover(ctx, userUpstreamURLs(userQuery(sel.Count, "", nil)))
+	if err != nil {
+		respondUpstreamError(w, err, "user data")
+		return
+	}
+
+	body, err := applyUserTransforms(resp.Body, []userTransform{projectUsers(paths, false)}, 0)
+	if err != nil {
+		log.Printf("Error transforming user data: %v", err)
+		RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+		return
+	}
+	batch, err := decodeUserBatch(body)
+	if err != nil {
+		log.Printf("Error decoding user data: %v", err)
+		RespondWithError(w, r, "Error decoding user data", http.StatusBadGateway)
+		return
+	}
+
+	// Set headers
+	setUpstreamDuration(w, resp.Duration)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, batch, http.StatusOK)
+
+	log.Println("Successfully served selected user fields")
+}
+
+// selectionPaths validates sel against the JSON fields of t and returns the
+// selected paths, each prefixed with prefix
+func selectionPaths(sel map[string]interface{}, t reflect.Type, prefix []string) ([][]string, error) {
+	names := make([]string, 0, len(sel))
+	for name := range sel {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var paths [][]string
+	for _, name := range names {
+		path := append(append([]string{}, prefix...), name)
+		field, ok := jsonField(t, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", strings.Join(path, "."))
+		}
+
+		switch v := sel[name].(type) {
+		case bool:
+			if !v {
+				return nil, fmt.Errorf("field %q must be selected with true or a nested selection", strings.Join(path, "."))
+			}
+			paths = append(paths, path)
+		case map[string]interface{}:
+			if field.Kind() != reflect.Struct || len(v) == 0 {
+				return nil, fmt.Errorf("field %q has no fields to select", strings.Join(path, "."))
+			}
+			nested, err := selectionPaths(v, field, path)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, nested...)
+		default:
+			return nil, fmt.Errorf("field %q must be selected with true or a nested selection", strings.Join(path, "."))
+		}
+	}
+	return paths, nil
+}
+
+// jsonField returns the type of the field of struct type t encoded as name
+func jsonField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == name {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
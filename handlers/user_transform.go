@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// errUnknownPath is returned by strict projections naming a path a user
+// does not have
+var errUnknownPath = errors.New("unknown path")
+
+// dicebearURL generates an avatar image from the seed in its query
+const dicebearURL = "https://api.dicebear.com/9.x/identicon/png"
+
+// dicebearSizes are the pixel sizes of the avatars in each picture field,
+// matching the randomuser.me pictures they replace
+var dicebearSizes = map[string]int{"large": 128, "medium": 72, "thumbnail": 48}
+
+// flattenSeparator joins the keys of nested fields in flattened users
+const flattenSeparator = "_"
+
+// userTransform modifies decoded users in place. offset is the index of the
+// first user within the full result set, so IDs stay stable across pages.
+type userTransform func(users []map[string]interface{}, offset int) error
+
+// userTransforms builds the transforms requested by r's query parameters
+func userTransforms(r *http.Request) ([]userTransform, error) {
+	q := r.URL.Query()
+	var transforms []userTransform
+
+	if q.Get("ordered") == "true" {
+		// First, so transforms that number users do so in the stable order
+		transforms = append(transforms, orderUsers)
+	}
+
+	if q.Get("with_id") == "true" {
+		transforms = append(transforms, injectTestIDs)
+	}
+	if domain := q.Get("email_domain"); domain != "" {
+		if !validDomain(domain) {
+			return nil, errors.New("email_domain must be a domain name such as mycorp.test")
+		}
+		// Before unique_email so addresses made equal by the new domain are
+		// still told apart
+		transforms = append(transforms, rewriteEmailDomain(strings.ToLower(domain)))
+	}
+	if q.Get("unique_email") == "true" {
+		transforms = append(transforms, uniqueEmails())
+	}
+	if style := q.Get("username_style"); style != "" {
+		if style != "dot" && style != "initial" && style != "numbered" {
+			return nil, errors.New("username_style must be dot, initial or numbered")
+		}
+		src := randomSource()
+		if seed := requestSeed(r); seed != "" {
+			src = newLockedRand(seedValue(seed))
+		}
+		transforms = append(transforms, styleUsernames(style, src))
+	}
+	switch q.Get("avatar") {
+	case "":
+	case "dicebear":
+		seed := requestSeed(r)
+		if seed == "" {
+			return nil, errors.New("avatar=dicebear requires a seed")
+		}
+		// Before picture_size so a size can be picked from these pictures
+		transforms = append(transforms, dicebearAvatars(seed))
+	default:
+		return nil, errors.New("avatar must be dicebear")
+	}
+	if size := q.Get("picture_size"); size != "" {
+		if !validPictureSize(size) {
+			return nil, errors.New("picture_size must be thumbnail, medium or large")
+		}
+		if typedUserFormat(requestFormat(r)) {
+			return nil, errors.New("picture_size is only supported for the json, ndjson and yaml formats")
+		}
+		// The avatar proxy picks the size itself from the full picture object
+		if q.Get("avatar_proxy") != "true" {
+			transforms = append(transforms, selectPicture(size))
+		}
+	}
+	if raw := q.Get("created_within"); raw != "" {
+		window, err := parseWindow("created_within", raw)
+		if err != nil {
+			return nil, err
+		}
+		// Seeded requests get the same offsets every time
+		src := randomSource()
+		if seed := requestSeed(r); seed != "" {
+			src = newLockedRand(seedValue(seed))
+		}
+		transforms = append(transforms, injectCreatedAt(src, time.Now(), window))
+	}
+	if raw := q.Get("project"); raw != "" {
+		if typedUserFormat(requestFormat(r)) {
+			return nil, errors.New("project is only supported for the json, ndjson and yaml formats")
+		}
+		paths, err := parseProjection(raw)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, projectUsers(paths, q.Get("project_strict") == "true"))
+	}
+	if q.Get("flatten") == "true" {
+		if typedUserFormat(requestFormat(r)) {
+			return nil, errors.New("flatten is only supported for the json and ndjson formats")
+		}
+		// Flatten last so fields added by other transforms are included
+		transforms = append(transforms, flattenUsers)
+	}
+
+	return transforms, nil
+}
+
+// applyUserTransforms decodes an upstream response, runs the transforms over
+// its results and re-encodes it, leaving the rest of the envelope untouched
+func applyUserTransforms(body []byte, transforms []userTransform, offset int) ([]byte, error) {
+	if len(transforms) == 0 {
+		return body, nil
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+
+	var users []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(envelope["results"]))
+	dec.UseNumber()
+	if err := dec.Decode(&users); err != nil {
+		return nil, err
+	}
+
+	for _, transform := range transforms {
+		if err := transform(users, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := json.Marshal(users)
+	if err != nil {
+		return nil, err
+	}
+	envelope["results"] = results
+	return json.Marshal(envelope)
+}
+
+// orderUsers sorts users by userSortKey, so a batch comes back in the same
+// order however the upstream returned it
+func orderUsers(users []map[string]interface{}, offset int) error {
+	sort.SliceStable(users, func(i, j int) bool {
+		return userSortKey(users[i]) < userSortKey(users[j])
+	})
+	return nil
+}
+
+// userSortKey orders users by last name, then first name, email and login
+// UUID, ignoring case in the names
+func userSortKey(user map[string]interface{}) string {
+	field := func(path ...string) string {
+		v, _ := lookupPath(user, path)
+		s, _ := v.(string)
+		return s
+	}
+	return strings.Join([]string{
+		strings.ToLower(field("name", "last")),
+		strings.ToLower(field("name", "first")),
+		field("email"),
+		field("login", "uuid"),
+	}, "\x00")
+}
+
+// injectTestIDs gives each user a sequential test_id, starting at 1 for the
+// first user of the full set
+func injectTestIDs(users []map[string]interface{}, offset int) error {
+	for i, user := range users {
+		user["test_id"] = offset + i + 1
+	}
+	return nil
+}
+
+// flattenUsers replaces each user's nested objects with top-level keys
+// joined by flattenSeparator, such as name_first and dob_age
+func flattenUsers(users []map[string]interface{}, offset int) error {
+	for _, user := range users {
+		flat := make(map[string]interface{}, len(user))
+		for k, v := range user {
+			flattenValue(k, v, flat)
+		}
+		for k := range user {
+			delete(user, k)
+		}
+		for k, v := range flat {
+			user[k] = v
+		}
+	}
+	return nil
+}
+
+// flattenValue stores v in out under key, recursing into objects and arrays
+// with the child's key or index appended
+func flattenValue(key string, v interface{}, out map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			flattenValue(key+flattenSeparator+k, child, out)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(key+flattenSeparator+strconv.Itoa(i), child, out)
+		}
+	default:
+		out[key] = v
+	}
+}
+
+// uniqueEmails returns a transform that makes every email unique across all
+// the pages of one request by tagging repeats with a "+n" suffix on the local
+// part, so jane@example.com becomes jane+2@example.com
+func uniqueEmails() userTransform {
+	seen := map[string]bool{}
+	return func(users []map[string]interface{}, offset int) error {
+		for _, user := range users {
+			email, ok := user["email"].(string)
+			if !ok || email == "" {
+				continue
+			}
+			unique := email
+			local, domain, found := strings.Cut(email, "@")
+			for n := 2; seen[strings.ToLower(unique)]; n++ {
+				if found {
+					unique = fmt.Sprintf("%s+%d@%s", local, n, domain)
+				} else {
+					unique = fmt.Sprintf("%s+%d", email, n)
+				}
+			}
+			seen[strings.ToLower(unique)] = true
+			user["email"] = unique
+		}
+		return nil
+	}
+}
+
+// rewriteEmailDomain returns a transform that moves each user's email onto
+// domain, keeping the local part
+func rewriteEmailDomain(domain string) userTransform {
+	return func(users []map[string]interface{}, offset int) error {
+		for _, user := range users {
+			email, ok := user["email"].(string)
+			if !ok || email == "" {
+				continue
+			}
+			local, _, _ := strings.Cut(email, "@")
+			user["email"] = local + "@" + domain
+		}
+		return nil
+	}
+}
+
+// validDomain reports whether s is a dot-separated list of labels made of
+// letters, digits and inner hyphens
+func validDomain(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// styleUsernames returns a transform that replaces each user's
+// login.username with one built from their name: first.last for dot, the
+// first initial and last name for initial, or the first name and a number
+// for numbered
+func styleUsernames(style string, src randSource) userTransform {
+	return func(users []map[string]interface{}, offset int) error {
+		for _, user := range users {
+			name, _ := user["name"].(map[string]interface{})
+			first, _ := name["first"].(string)
+			last, _ := name["last"].(string)
+			first, last = usernamePart(first), usernamePart(last)
+
+			var username string
+			switch style {
+			case "dot":
+				username = first + "." + last
+			case "initial":
+				if r := []rune(first); len(r) > 0 {
+					username = string(r[0])
+				}
+				username += last
+			case "numbered":
+				username = first + strconv.Itoa(1+src.Intn(999))
+			}
+
+			login, ok := user["login"].(map[string]interface{})
+			if !ok {
+				login = map[string]interface{}{}
+				user["login"] = login
+			}
+			login["username"] = username
+		}
+		return nil
+	}
+}
+
+// usernamePart lower-cases a name and drops everything but letters and
+// digits, so "O'Brien" becomes "obrien"
+func usernamePart(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// selectPicture returns a transform that replaces each user's picture object
+// with the URL of the given size
+func selectPicture(size string) userTransform {
+	return func(users []map[string]interface{}, offset int) error {
+		for _, user := range users {
+			if picture, ok := user["picture"].(map[string]interface{}); ok {
+				user["picture"] = picture[size]
+			}
+		}
+		return nil
+	}
+}
+
+// injectCreatedAt returns a transform that gives each user a created_at
+// timestamp at a random point in the window leading up to now
+func injectCreatedAt(src randSource, now time.Time, window time.Duration) userTransform {
+	return func(users []map[string]interface{}, offset int) error {
+		for _, user := range users {
+			ago := time.Duration(src.Int63() % int64(window))
+			user["created_at"] = now.Add(-ago).UTC().Format(time.RFC3339)
+		}
+		return nil
+	}
+}
+
+// parseProjection splits a comma-separated list of dot-notation paths such
+// as name.first,email into their segments
+func parseProjection(raw string) ([][]string, error) {
+	var paths [][]string
+	for _, p := range strings.Split(raw, ",") {
+		segments := strings.Split(strings.TrimSpace(p), ".")
+		for _, seg := range segments {
+			if seg == "" {
+				return nil, fmt.Errorf("project path %q must be dot-separated field names", p)
+			}
+		}
+		paths = append(paths, segments)
+	}
+	return paths, nil
+}
+
+// projectUsers returns a transform that keeps only the given paths of each
+// user, preserving their nesting. Paths a user does not have are skipped,
+// or fail with errUnknownPath when strict.
+func projectUsers(paths [][]string, strict bool) userTransform {
+	return func(users []map[string]interface{}, offset int) error {
+		for _, user := range users {
+			projected := map[string]interface{}{}
+			for _, path := range paths {
+				value, ok := lookupPath(user, path)
+				if !ok {
+					if strict {
+						return fmt.Errorf("%w %q", errUnknownPath, strings.Join(path, "."))
+					}
+					continue
+				}
+				setPath(projected, path, value)
+			}
+			for k := range user {
+				delete(user, k)
+			}
+			for k, v := range projected {
+				user[k] = v
+			}
+		}
+		return nil
+	}
+}
+
+// lookupPath returns the value at path within obj's nested objects
+func lookupPath(obj map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = obj
+	for _, seg := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[seg]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setPath stores value at path within obj, creating intermediate objects
+func setPath(obj map[string]interface{}, path []string, value interface{}) {
+	for _, seg := range path[:len(path)-1] {
+		child, ok := obj[seg].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			obj[seg] = child
+		}
+		obj = child
+	}
+	obj[path[len(path)-1]] = value
+}
+
+// dicebearAvatars returns a transform that replaces each user's pictures
+// with avatars derived from seed and the user's position, so the same seed
+// always yields the same avatars
+func dicebearAvatars(seed string) userTransform {
+	return func(users []map[string]interface{}, offset int) error {
+		for i, user := range users {
+			picture := make(map[string]interface{}, len(dicebearSizes))
+			for field, size := range dicebearSizes {
+				q := url.Values{}
+				q.Set("seed", fmt.Sprintf("%s-%d", seed, offset+i+1))
+				q.Set("size", strconv.Itoa(size))
+				picture[field] = dicebearURL + "?" + q.Encode()
+			}
+			user["picture"] = picture
+		}
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// RandomUserResponse mirrors the randomuser.me API response
+type RandomUserResponse struct {
+	Results []RandomUser   `json:"results"`
+	Info    RandomUserInfo `json:"info"`
+}
+
+// RandomUserInfo describes the request that produced a randomuser.me response
+type RandomUserInfo struct {
+	Seed    string `json:"seed"`
+	Results int    `json:"results"`
+	Page    int    `json:"page"`
+	Version string `json:"version"`
+}
+
+// RandomUser is a single generated user profile
+type RandomUser struct {
+	Gender     string             `json:"gender"`
+	Name       RandomUserName     `json:"name"`
+	Location   RandomUserLocation `json:"location"`
+	Email      string             `json:"email"`
+	Login      RandomUserLogin    `json:"login"`
+	Dob        RandomUserDate     `json:"dob"`
+	Registered RandomUserDate     `json:"registered"`
+	Phone      string             `json:"phone"`
+	Cell       string             `json:"cell"`
+	ID         RandomUserID       `json:"id"`
+	Picture    RandomUserPicture  `json:"picture"`
+	Nat        string             `json:"nat"`
+}
+
+// RandomUserName is a user's full name
+type RandomUserName struct {
+	Title string `json:"title"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+// RandomUserLocation is a user's postal address
+type RandomUserLocation struct {
+	Street struct {
+		Number int    `json:"number"`
+		Name   string `json:"name"`
+	} `json:"street"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Country string `json:"country"`
+	// Postcode is a number for some nationalities and a string for others
+	Postcode    interface{} `json:"postcode"`
+	Coordinates struct {
+		Latitude  string `json:"latitude"`
+		Longitude string `json:"longitude"`
+	} `json:"coordinates"`
+	Timezone struct {
+		Offset      string `json:"offset"`
+		Description string `json:"description"`
+	} `json:"timezone"`
+}
+
+// RandomUserLogin holds a user's generated credentials
+type RandomUserLogin struct {
+	UUID     string `json:"uuid"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Salt     string `json:"salt"`
+	MD5      string `json:"md5"`
+	SHA1     string `json:"sha1"`
+	SHA256   string `json:"sha256"`
+}
+
+// RandomUserDate is a date paired with the age it implies
+type RandomUserDate struct {
+	Date string `json:"date"`
+	Age  int    `json:"age"`
+}
+
+// RandomUserID is a national identifier; Value is null for some nationalities
+type RandomUserID struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"`
+}
+
+// RandomUserPicture holds avatar URLs in each available size
+type RandomUserPicture struct {
+	Large     string `json:"large"`
+	Medium    string `json:"medium"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+// validPictureSize reports whether size names one of the picture sizes
+func validPictureSize(size string) bool {
+	return size == "thumbnail" || size == "medium" || size == "large"
+}
+
+// url returns the URL for size, defaulting to the large picture
+func (p RandomUserPicture) url(size string) string {
+	switch size {
+	case "thumbnail":
+		return p.Thumbnail
+	case "medium":
+		return p.Medium
+	default:
+		return p.Large
+	}
+}
+
+// postcode returns the postcode as text regardless of its JSON type
+func (l RandomUserLocation) postcode() string {
+	if l.Postcode == nil {
+		return ""
+	}
+	return fmt.Sprint(l.Postcode)
+}
+
+// checkUserSchema decodes body strictly into RandomUserResponse, returning an
+// error naming the first field randomuser.me sent that the types do not know
+func checkUserSchema(body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	var users RandomUserResponse
+	return dec.Decode(&users)
+}
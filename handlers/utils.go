@@ -2,8 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
 // ErrorResponse represents an error response
@@ -13,19 +20,46 @@ type ErrorResponse struct {
 	Code    int    `json:"code"`
 }
 
-// RespondWithError sends a JSON error response
-func RespondWithError(w http.ResponseWriter, message string, code int) {
+// textFormats are the response formats whose clients expect plain-text
+// rather than JSON errors
+var textFormats = []string{"text", "plain", "apache", "nginx", "vcard", "sql"}
+
+// RespondWithError sends an error response in the format the request asked
+// for: a plain-text line for text formats, JSON otherwise
+func RespondWithError(w http.ResponseWriter, r *http.Request, message string, code int) {
 	log.Printf("Error response: %s (code: %d)", message, code)
-	
+
+	if wantsTextErrors(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, "%s: %s\n", http.StatusText(code), message)
+		return
+	}
+	writeJSONError(w, message, code)
+}
+
+// wantsTextErrors reports whether r asked for one of the textFormats
+func wantsTextErrors(r *http.Request) bool {
+	format := requestFormat(r)
+	for _, f := range textFormats {
+		if format == f {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONError writes an ErrorResponse
+func writeJSONError(w http.ResponseWriter, message string, code int) {
 	response := ErrorResponse{
 		Error:   http.StatusText(code),
 		Message: message,
 		Code:    code,
 	}
-	
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	
+
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Error encoding error response: %v", err)
 		// If we can't encode the error, fall back to plain text
@@ -38,10 +72,158 @@ func RespondWithError(w http.ResponseWriter, message string, code int) {
 func RespondWithJSON(w http.ResponseWriter, data interface{}, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	
+
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		log.Printf("Error encoding JSON response: %v", err)
-		RespondWithError(w, "Internal server error", http.StatusInternalServerError)
+		writeJSONError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-}
\ No newline at end of file
+}
+
+// queryInt reads an integer query parameter, returning def when it is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return n, nil
+}
+
+// queryFloat reads a float query parameter, returning def when it is absent
+func queryFloat(r *http.Request, name string, def float64) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return f, nil
+}
+
+// defaultMaxCount is the count cap shared by the generators unless MAX_COUNT
+// says otherwise
+const defaultMaxCount = 1000
+
+// parseCount reads the "count" query parameter, defaulting to 1 and
+// rejecting values outside 1..MAX_COUNT
+func parseCount(r *http.Request) (int, error) {
+	return parseCountMax(r, envInt("MAX_COUNT", defaultMaxCount))
+}
+
+// parseCountMax is parseCount for handlers that need their own cap in place
+// of MAX_COUNT
+func parseCountMax(r *http.Request, max int) (int, error) {
+	count, err := queryInt(r, "count", 1)
+	if err != nil {
+		return 0, err
+	}
+	if count < 1 || count > max {
+		return 0, fmt.Errorf("count must be between 1 and %d", max)
+	}
+	return count, nil
+}
+
+// maxWindow bounds the time windows accepted by parseWindow
+const maxWindow = 100 * 365 * 24 * time.Hour
+
+// parseWindow parses a positive time window written as a whole number of
+// days or hours, such as 30d or 12h
+func parseWindow(name, value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "h": time.Hour}
+	if value != "" {
+		if unit, ok := units[value[len(value)-1:]]; ok {
+			n, err := strconv.Atoi(value[:len(value)-1])
+			if err == nil && n >= 1 && time.Duration(n) <= maxWindow/unit {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%s must be a number of days or hours such as 30d or 12h", name)
+}
+
+// envInt reads an integer environment variable, returning def when it is
+// unset or invalid
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a float environment variable, returning def when it is
+// unset or invalid
+func envFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %v", key, value, def)
+		return def
+	}
+	return f
+}
+
+// envDuration reads a duration environment variable, returning def when it
+// is unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// jsonErrorStatus is the status for a request body that failed to decode:
+// 413 when it was cut off by a body limit, 400 otherwise
+func jsonErrorStatus(err error) int {
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// describeJSONError turns a JSON decoding error into a message that tells the
+// client what is wrong with the request body
+func describeJSONError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &sizeErr):
+		return fmt.Sprintf("request body exceeds %d bytes", sizeErr.Limit)
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("malformed JSON at byte offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Sprintf("field %q must be of type %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return fmt.Sprintf("value must be of type %s, got %s", typeErr.Type, typeErr.Value)
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body is truncated"
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// uuidEpochOffset is the number of 100ns intervals between the UUID epoch
+// (1582-10-15) and the Unix epoch
+const uuidEpochOffset = 0x01B21DD213814000
+
+// UUIDResponse is the response body for the random UUID endpoint
+type UUIDResponse struct {
+	UUIDs []string `json:"uuids"`
+}
+
+// UUID handles requests for version 1, 4 or 7 UUIDs
+func UUID(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random UUID")
+
+	var generate func() ([16]byte, error)
+	switch version := r.URL.Query().Get("version"); version {
+	case "", "4":
+		generate = newUUIDv4
+	case "1":
+		generate = newUUIDv1
+	case "7":
+		generate = newUUIDv7
+	default:
+		RespondWithError(w, r, "version must be 1, 4 or 7", http.StatusBadRequest)
+		return
+	}
+
+	count, err := parseCount(r)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uuids := make([]string, count)
+	for i := range uuids {
+		u, err := generate()
+		if err != nil {
+			log.Printf("Error generating UUID: %v", err)
+			RespondWithError(w, r, "Error generating UUID", http.StatusInternalServerError)
+			return
+		}
+		uuids[i] = formatUUID(u)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	RespondWithJSON(w, UUIDResponse{UUIDs: uuids}, http.StatusOK)
+
+	log.Println("Successfully served random UUID")
+}
+
+// newUUIDv4 returns a random UUID
+func newUUIDv4() ([16]byte, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return u, err
+	}
+	setUUIDVersion(&u, 4)
+	return u, nil
+}
+
+// newUUIDv1 returns a time-based UUID. The clock sequence and node are
+// random, with the multicast bit set so the node cannot clash with a real
+// MAC address.
+func newUUIDv1() ([16]byte, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[8:]); err != nil {
+		return u, err
+	}
+
+	ts := uint64(time.Now().UnixNano()/100) + uuidEpochOffset
+	binary.BigEndian.PutUint32(u[0:4], uint32(ts))
+	binary.BigEndian.PutUint16(u[4:6], uint16(ts>>32))
+	binary.BigEndian.PutUint16(u[6:8], uint16(ts>>48))
+	u[10] |= 0x01
+
+	setUUIDVersion(&u, 1)
+	return u, nil
+}
+
+// uuidV7State keeps version 7 UUIDs monotonic within a millisecond
+var uuidV7State struct {
+	mu      sync.Mutex
+	lastMS  int64
+	counter uint16
+}
+
+// newUUIDv7 returns a time-ordered UUID: a 48-bit Unix millisecond timestamp
+// followed by a 12-bit counter and random bits. The counter restarts at a
+// random value each millisecond and is incremented for UUIDs generated
+// within the same one, so values sort in generation order.
+func newUUIDv7() ([16]byte, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return u, err
+	}
+
+	s := &uuidV7State
+	s.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= s.lastMS {
+		ms = s.lastMS
+		s.counter++
+		// On counter overflow borrow the next millisecond
+		if s.counter > 0xFFF {
+			ms++
+			s.counter = 0
+		}
+	} else {
+		// Start in the lower half so there is room to increment
+		s.counter = binary.BigEndian.Uint16(u[6:8]) & 0x7FF
+	}
+	s.lastMS = ms
+	counter := s.counter
+	s.mu.Unlock()
+
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+	binary.BigEndian.PutUint16(u[6:8], counter)
+
+	setUUIDVersion(&u, 7)
+	return u, nil
+}
+
+// setUUIDVersion stamps the version nibble and the RFC 4122 variant bits
+func setUUIDVersion(u *[16]byte, version byte) {
+	u[6] = u[6]&0x0F | version<<4
+	u[8] = u[8]&0x3F | 0x80
+}
+
+// formatUUID renders u in the canonical 8-4-4-4-12 form
+func formatUUID(u [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// vcardEscaper escapes text values per RFC 2426
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeVCards renders users as consecutive vCard 3.0 entries
+func writeVCards(sb *strings.Builder, users []RandomUser) {
+	for _, u := range users {
+		e := vcardEscaper.Replace
+		lines := []string{
+			"BEGIN:VCARD",
+			"VERSION:3.0",
+			fmt.Sprintf("N:%s;%s;;%s;", e(u.Name.Last), e(u.Name.First), e(u.Name.Title)),
+			fmt.Sprintf("FN:%s", e(strings.TrimSpace(u.Name.First+" "+u.Name.Last))),
+			fmt.Sprintf("EMAIL;TYPE=INTERNET:%s", e(u.Email)),
+			fmt.Sprintf("TEL;TYPE=HOME:%s", e(u.Phone)),
+			fmt.Sprintf("TEL;TYPE=CELL:%s", e(u.Cell)),
+			fmt.Sprintf("ADR;TYPE=HOME:;;%s;%s;%s;%s;%s",
+				e(strings.TrimSpace(fmt.Sprintf("%d %s", u.Location.Street.Number, u.Location.Street.Name))),
+				e(u.Location.City), e(u.Location.State), e(u.Location.postcode()), e(u.Location.Country)),
+		}
+		if u.Dob.Date != "" && len(u.Dob.Date) >= 10 {
+			lines = append(lines, "BDAY:"+u.Dob.Date[:10])
+		}
+		if u.Picture.Large != "" {
+			lines = append(lines, "PHOTO;VALUE=URI:"+u.Picture.Large)
+		}
+		lines = append(lines, "END:VCARD")
+
+		// vCard lines are CRLF-terminated
+		sb.WriteString(strings.Join(lines, "\r\n"))
+		sb.WriteString("\r\n")
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+const (
+	defaultXMLDepth    = 3
+	maxXMLDepth        = 10
+	defaultXMLElements = 10
+	maxXMLElements     = 1000
+
+	// maxXMLAttrs bounds the number of attributes on each element
+	maxXMLAttrs = 3
+)
+
+// xmlNode is a generated element. Leaves carry text, others carry children.
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []*xmlNode `xml:",any"`
+}
+
+// XML handles requests for a random, well-formed XML document
+func XML(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handling request for random XML")
+
+	depth, err := queryInt(r, "depth", defaultXMLDepth)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if depth < 1 || depth > maxXMLDepth {
+		RespondWithError(w, r, fmt.Sprintf("depth must be between 1 and %d", maxXMLDepth), http.StatusBadRequest)
+		return
+	}
+
+	elements, err := queryInt(r, "elements", defaultXMLElements)
+	if err != nil {
+		RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if elements < 1 || elements > maxXMLElements {
+		RespondWithError(w, r, fmt.Sprintf("elements must be between 1 and %d", maxXMLElements), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := xml.MarshalIndent(randomXMLDocument(randomSource(), depth, elements), "", "  ")
+	if err != nil {
+		log.Printf("Error encoding XML: %v", err)
+		RespondWithError(w, r, "Error encoding XML", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, xml.Header+string(doc)+"\n"); err != nil {
+		log.Printf("Error writing response: %v", err)
+		// Cannot write error to client at this point
+		return
+	}
+
+	log.Println("Successfully served random XML")
+}
+
+// randomXMLDocument builds a root element holding elements descendants,
+// each attached to a random parent so no element is nested deeper than
+// depth levels below the root
+func randomXMLDocument(src randSource, depth, elements int) *xmlNode {
+	root := randomXMLElement(src)
+	// parents are the elements that can still take a child, levels their depth
+	parents, levels := []*xmlNode{root}, []int{0}
+	for i := 0; i < elements; i++ {
+		p := src.Intn(len(parents))
+		child := randomXMLElement(src)
+		parents[p].Children = append(parents[p].Children, child)
+		if levels[p]+1 < depth {
+			parents, levels = append(parents, child), append(levels, levels[p]+1)
+		}
+	}
+
+	// Only leaves carry text, so no element has mixed content
+	var fill func(*xmlNode)
+	fill = func(n *xmlNode) {
+		if len(n.Children) == 0 {
+			n.Text = randomJSONString(src)
+		}
+		for _, c := range n.Children {
+			fill(c)
+		}
+	}
+	fill(root)
+	return root
+}
+
+// randomXMLElement returns an empty element named after a corpus word with
+// up to maxXMLAttrs distinct attributes
+func randomXMLElement(src randSource) *xmlNode {
+	n := &xmlNode{XMLName: xml.Name{Local: loremWords[src.Intn(len(loremWords))]}}
+	seen := map[string]bool{}
+	for i := src.Intn(maxXMLAttrs + 1); i > 0; i-- {
+		name := loremWords[src.Intn(len(loremWords))]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: randomJSONString(src)})
+	}
+	return n
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// yamlPlainKey matches mapping keys that need no quoting
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// yamlReservedKeys match yamlPlainKey but YAML 1.1 parsers read them as
+// booleans or null, so they are quoted in any case
+var yamlReservedKeys = map[string]bool{
+	"null": true, "true": true, "false": true,
+	"yes": true, "no": true, "on": true, "off": true, "y": true, "n": true,
+}
+
+// yamlKey renders key as a mapping key, quoting it unless it is plain
+func yamlKey(key string) string {
+	if yamlPlainKey.MatchString(key) && !yamlReservedKeys[strings.ToLower(key)] {
+		return key
+	}
+	quoted, _ := json.Marshal(key)
+	return string(quoted)
+}
+
+// yamlNode is a decoded JSON value that keeps object keys in document order
+type yamlNode struct {
+	// scalar holds the JSON literal of strings, numbers, booleans and null,
+	// which are all valid YAML flow scalars
+	scalar string
+	keys   []string
+	values []*yamlNode
+	isMap  bool
+	isList bool
+}
+
+// RespondWithYAML sends data, encoded as it would be for RespondWithJSON, as
+// a YAML document
+func RespondWithYAML(w http.ResponseWriter, data interface{}, code int) {
+	body, err := encodeYAML(data)
+	if err != nil {
+		log.Printf("Error encoding YAML response: %v", err)
+		writeJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// encodeYAML renders v as block-style YAML. v is marshalled to JSON first so
+// struct tags and custom marshallers apply exactly as they do for JSON.
+func encodeYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeYAMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	if root.isMap && len(root.keys) > 0 || root.isList && len(root.values) > 0 {
+		writeYAMLBlock(&buf, root, 0)
+	} else {
+		buf.WriteString(root.inline() + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeYAMLNode reads one JSON value from dec
+func decodeYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		node := &yamlNode{isMap: tok == '{', isList: tok == '['}
+		for dec.More() {
+			if node.isMap {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			child, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, child)
+		}
+		// Consume the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		quoted, _ := json.Marshal(tok)
+		return &yamlNode{scalar: string(quoted)}, nil
+	case nil:
+		return &yamlNode{scalar: "null"}, nil
+	default:
+		return &yamlNode{scalar: fmt.Sprint(tok)}, nil
+	}
+}
+
+// inline renders scalars and empty collections on a single line
+func (n *yamlNode) inline() string {
+	switch {
+	case n.isMap:
+		return "{}"
+	case n.isList:
+		return "[]"
+	default:
+		return n.scalar
+	}
+}
+
+// block reports whether n is written as an indented block on its own lines
+func (n *yamlNode) block() bool {
+	return len(n.values) > 0
+}
+
+// writeYAMLBlock writes a non-empty map or list at the given indent
+func writeYAMLBlock(buf *bytes.Buffer, n *yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for i, child := range n.values {
+		if n.isMap {
+			key := yamlKey(n.keys[i])
+			if child.block() {
+				fmt.Fprintf(buf, "%s%s:\n", pad, key)
+				writeYAMLBlock(buf, child, indent+2)
+			} else {
+				fmt.Fprintf(buf, "%s%s: %s\n", pad, key, child.inline())
+			}
+			continue
+		}
+
+		if !child.block() {
+			fmt.Fprintf(buf, "%s- %s\n", pad, child.inline())
+			continue
+		}
+		// Start the nested block on the dash line, indenting the rest under it
+		var nested bytes.Buffer
+		writeYAMLBlock(&nested, child, indent+2)
+		buf.WriteString(pad + "- ")
+		buf.Write(nested.Bytes()[indent+2:])
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/github/testdatabot/handlers"
 )
@@ -18,42 +21,83 @@ func main() {
 }
 
 func run() error {
-	// Set up logging
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	// Resolve the configuration and set up logging
+	cfg := handlers.LoadConfig()
+	handlers.ConfigureLogger(cfg.LogPrefix)
 	log.Println("Starting TestDataBot API server...")
 
+	// Report the configuration as resolved at startup
+	handlers.LogConfig(cfg)
+
 	// Register routes
 	mux := http.NewServeMux()
-	mux.HandleFunc("/random-commit-message", handlers.CommitMessage)
-	mux.HandleFunc("/random-lorem-ipsum", handlers.Loripsum)
-	mux.HandleFunc("/random-user", handlers.User)
-	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
-	})
-
-	// Configure the HTTP server
-	port := getEnvOrDefault("PORT", "8080")
+	handlers.Register(mux)
+
+	// Warm upstream connections in the background so startup is not delayed
+	if cfg.Prewarm {
+		go handlers.Prewarm(context.Background())
+	}
+
+	// Toggle verbose logging on SIGUSR1
+	watchDebugSignal()
+
+	// Apply middlewares, outermost first. Tracing comes before logging so
+	// log lines carry the trace ID.
+	handler := handlers.Chain(mux,
+		handlers.TracingMiddleware,
+		handlers.LoggingMiddleware,
+		handlers.APIKeyMiddleware,
+		handlers.UniqueClientsMiddleware(cfg.UniqueClientsMax),
+		handlers.RequestOptionsMiddleware,
+		handlers.SecurityHeadersMiddleware(cfg.SecurityHeaders),
+		handlers.ErrorRatioMiddleware(cfg.ErrorRatioThreshold, cfg.ErrorRatioWindow),
+		handlers.URLLengthMiddleware,
+		handlers.DebugBodiesMiddleware,
+		handlers.MaxInFlightMiddleware(cfg.MaxInFlight),
+		handlers.RetryBudgetMiddleware,
+		handlers.ChaosMiddleware,
+		handlers.CaseMiddleware,
+		handlers.NumbersMiddleware,
+	)
+
+	// Configure the HTTP server, giving every request the deployment metadata
+	baseCtx := handlers.WithMetadata(context.Background(), handlers.LoadMetadata())
 	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         ":" + cfg.Port,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
 
 	// Start the server
-	log.Printf("Server listening on port %s", port)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server listening on port %s", cfg.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Drain in-flight requests on SIGINT or SIGTERM
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
 		return fmt.Errorf("server error: %w", err)
+	case sig := <-stop:
+		log.Printf("Received %s", sig)
+		return handlers.Shutdown(server, cfg.ShutdownTimeout)
 	}
-
-	return nil
 }
 
-// getEnvOrDefault gets an environment variable or returns a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultValue
-}
\ No newline at end of file
+// watchDebugSignal flips debug logging each time the process receives SIGUSR1
+func watchDebugSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	go func() {
+		for range sigs {
+			log.Printf("Debug logging enabled: %v", handlers.ToggleDebug())
+		}
+	}()
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// flushCaches calls the cache flush endpoint with token as the bearer token
+func flushCaches(t *testing.T, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/admin/cache/flush", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handlers.AdminCacheFlush(rr, req)
+	return rr
+}
+
+func TestAdminCacheFlushRequiresToken(t *testing.T) {
+	if rr := flushCaches(t, "anything"); rr.Code != http.StatusForbidden {
+		t.Errorf("without ADMIN_TOKEN: expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	for _, token := range []string{"", "wrong", "s3cret-but-longer"} {
+		rr := flushCaches(t, token)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected status %d, got %d", token, http.StatusUnauthorized, rr.Code)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: expected a WWW-Authenticate challenge", token)
+		}
+	}
+}
+
+func TestAdminCacheFlush(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	mockUserUpstream(t)
+
+	// Start from an empty cache, which other tests share
+	if rr := flushCaches(t, "s3cret"); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	prefix := "flush-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, seed := range []string{prefix + "-a", prefix + "-b", prefix + "-a"} {
+		handlers.User(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-user?seed="+seed, nil))
+	}
+
+	rr := flushCaches(t, "s3cret")
+	var resp handlers.CacheFlushResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if resp.Evicted != 2 {
+		t.Errorf("expected 2 evicted entries, got %d", resp.Evicted)
+	}
+
+	// The next seeded request misses the cache again
+	rr = httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?seed="+prefix+"-a", nil))
+	if got := rr.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("expected a cache miss after flushing, got X-Cache %q", got)
+	}
+}
+
+func TestAdminMetricsReset(t *testing.T) {
+	req := httptest.NewRequest("POST", "/admin/metrics/reset", nil)
+	rr := httptest.NewRecorder()
+	handlers.AdminMetricsReset(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("without ADMIN_TOKEN: expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handlers.AdminMetricsReset(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var resp handlers.MetricsResetResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if _, ok := resp.Previous["upstream_retries_total"]; !ok {
+		t.Errorf("expected previous values for every counter, got %v", resp.Previous)
+	}
+	if got := metricValue(t, "cache_hits_total"); got != 0 {
+		t.Errorf("expected cache_hits_total to be 0 after reset, got %d", got)
+	}
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestRandomBytesSize(t *testing.T) {
+	for _, size := range []int{0, 1, 1024, 100000} {
+		rr := httptest.NewRecorder()
+		handlers.RandomBytes(rr, httptest.NewRequest("GET", "/random-bytes?size="+strconv.Itoa(size), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("size=%d: handler returned wrong status code: got %v want %v", size, rr.Code, http.StatusOK)
+		}
+		if rr.Body.Len() != size {
+			t.Errorf("size=%d: got %d bytes", size, rr.Body.Len())
+		}
+		if got := rr.Header().Get("Content-Length"); got != strconv.Itoa(size) {
+			t.Errorf("size=%d: wrong Content-Length %q", size, got)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/octet-stream" {
+			t.Errorf("size=%d: wrong Content-Type %q", size, ct)
+		}
+	}
+}
+
+func TestRandomBytesAreRandom(t *testing.T) {
+	get := func() []byte {
+		rr := httptest.NewRecorder()
+		handlers.RandomBytes(rr, httptest.NewRequest("GET", "/random-bytes?size=64", nil))
+		return rr.Body.Bytes()
+	}
+	if bytes.Equal(get(), get()) {
+		t.Errorf("expected two requests to return different bytes")
+	}
+}
+
+func TestRandomBytesCap(t *testing.T) {
+	t.Setenv("RANDOM_BYTES_MAX", "100")
+	for _, query := range []string{"size=101", "size=-1", "size=lots"} {
+		rr := httptest.NewRecorder()
+		handlers.RandomBytes(rr, httptest.NewRequest("GET", "/random-bytes?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestValidateCard(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+		brand  string
+	}{
+		{"4111 1111 1111 1111", true, "visa"},
+		{"4111-1111-1111-1112", false, "visa"},
+		{"5555555555554444", true, "mastercard"},
+		{"2221000000000009", true, "mastercard"},
+		{"378282246310005", true, "amex"},
+		{"6011111111111117", true, "discover"},
+		{"1234567812345670", true, "unknown"},
+		{"79927398710", false, "unknown"},
+	}
+
+	for _, tt := range tests {
+		body := `{"number":"` + tt.number + `"}`
+		rr := httptest.NewRecorder()
+		handlers.ValidateCard(rr, httptest.NewRequest("POST", "/validate-card", strings.NewReader(body)))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: wrong status code: got %v want %v", tt.number, rr.Code, http.StatusOK)
+		}
+
+		var resp handlers.CardValidationResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: error decoding response: %v", tt.number, err)
+		}
+		if resp.Valid != tt.valid || resp.Brand != tt.brand {
+			t.Errorf("%s: got valid=%v brand=%q, want valid=%v brand=%q", tt.number, resp.Valid, resp.Brand, tt.valid, tt.brand)
+		}
+	}
+}
+
+func TestValidateCardRejectsNonDigits(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.ValidateCard(rr, httptest.NewRequest("POST", "/validate-card", strings.NewReader(`{"number":"4111-abcd"}`)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
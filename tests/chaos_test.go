@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestChaosMiddlewareAppliesQueryDelay(t *testing.T) {
+	t.Setenv("CHAOS_ENABLED", "true")
+
+	called := false
+	handler := handlers.ChaosMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping?delay=50ms", nil))
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected at least 50ms delay, got %s", elapsed)
+	}
+	if !called {
+		t.Errorf("expected handler to be called after the delay")
+	}
+}
+
+func TestChaosMiddlewareIgnoresQueryWhenDisabled(t *testing.T) {
+	t.Setenv("CHAOS_ENABLED", "")
+
+	handler := handlers.ChaosMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping?delay=2s", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected no delay when chaos is disabled, got %s", elapsed)
+	}
+}
+
+func TestChaosMiddlewareClampsDelay(t *testing.T) {
+	t.Setenv("CHAOS_ENABLED", "true")
+	t.Setenv("CHAOS_MAX_DELAY", "20ms")
+
+	handler := handlers.ChaosMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping?delay=1h", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected delay to be clamped, got %s", elapsed)
+	}
+}
+
+func TestChaosMiddlewareAbortsOnCancel(t *testing.T) {
+	t.Setenv("CHAOS_DELAY", "5s")
+
+	called := false
+	handler := handlers.ChaosMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/_ping", nil).WithContext(ctx)
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected cancellation to abort the delay, took %s", elapsed)
+	}
+	if called {
+		t.Errorf("handler should not run after the request was cancelled")
+	}
+}
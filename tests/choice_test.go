@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// sampleChoices requests count choices for options, repeated rounds times
+func sampleChoices(t *testing.T, options string, rounds int) map[string]int {
+	t.Helper()
+	seen := map[string]int{}
+	for i := 0; i < rounds; i++ {
+		rr := httptest.NewRecorder()
+		handlers.Choice(rr, httptest.NewRequest("GET", "/random-choice?count=1000&options="+options, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+
+		var resp handlers.ChoiceResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		for _, choice := range resp.Choices {
+			seen[choice]++
+		}
+	}
+	return seen
+}
+
+// assertRatio checks the observed share of value is within tolerance of want
+func assertRatio(t *testing.T, seen map[string]int, value string, want float64) {
+	t.Helper()
+	total := 0
+	for _, n := range seen {
+		total += n
+	}
+	got := float64(seen[value]) / float64(total)
+	if math.Abs(got-want) > 0.03 {
+		t.Errorf("option %q chosen %.3f of the time, want %.3f", value, got, want)
+	}
+}
+
+func TestChoiceWeightedDistribution(t *testing.T) {
+	seen := sampleChoices(t, "a:3,b:1,c:1", 10)
+
+	assertRatio(t, seen, "a", 0.6)
+	assertRatio(t, seen, "b", 0.2)
+	assertRatio(t, seen, "c", 0.2)
+}
+
+func TestChoiceUniformWithoutWeights(t *testing.T) {
+	seen := sampleChoices(t, "x,y", 10)
+
+	assertRatio(t, seen, "x", 0.5)
+	assertRatio(t, seen, "y", 0.5)
+}
+
+func TestChoiceRejectsInvalidWeights(t *testing.T) {
+	for _, options := range []string{"a:0,b:1", "a:-2", "a:heavy", "", "a:NaN,b:1", "a:Inf", "a:%2BInf", "a:-inf", "a:1e308,b:1e308"} {
+		rr := httptest.NewRecorder()
+		handlers.Choice(rr, httptest.NewRequest("GET", "/random-choice?options="+options, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("options %q: wrong status code: got %v want %v", options, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
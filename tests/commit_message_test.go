@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestTruncateAtWord(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"short message", 20, "short message"},
+		{"hello world foo bar", 10, "hello..."},
+		{"hello world", 8, "hello..."},
+		{"supercalifragilistic", 10, "superca..."},
+		{"abcdef", 2, "ab"},
+	}
+	for _, tt := range tests {
+		got := handlers.TruncateAtWord(tt.in, tt.max)
+		if got != tt.want {
+			t.Errorf("TruncateAtWord(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+		if len([]rune(got)) > tt.max {
+			t.Errorf("TruncateAtWord(%q, %d) = %q exceeds max length", tt.in, tt.max, got)
+		}
+	}
+}
+
+func TestCommitMessageMaxLengthRefetches(t *testing.T) {
+	messages := []string{
+		"This commit message is far too long to fit the limit\n",
+		"Also much too long for the configured limit\n",
+		"Fix typo\n",
+	}
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(messages[calls%len(messages)]))
+		calls++
+	})
+
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message?max_length=10", nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if body := rr.Body.String(); body != "Fix typo" {
+		t.Errorf("handler returned unexpected body: %q", body)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 upstream calls, got %d", calls)
+	}
+}
+
+func TestCommitMessageMaxLengthGivesUp(t *testing.T) {
+	mockUpstream(t, "COMMIT_MESSAGE_URL", serveText("text/plain", "This commit message never fits\n"))
+
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message?max_length=5", nil))
+
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
+	}
+}
+
+func TestCommitMessageMaxLengthTruncates(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("Refactor everything because reasons\n"))
+	})
+
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message?max_length=20&truncate=true", nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	body := rr.Body.String()
+	if body != "Refactor..." {
+		t.Errorf("handler returned unexpected body: %q", body)
+	}
+	if calls != 1 {
+		t.Errorf("expected a single upstream call when truncating, got %d", calls)
+	}
+}
+
+func TestCommitMessageConventional(t *testing.T) {
+	mockCommitUpstream(t)
+	pattern := regexp.MustCompile(`^(feat|fix|chore|docs)(\([a-z]+\))?: \S.*$`)
+
+	scoped := false
+	for i := 0; i < 50; i++ {
+		rr := httptest.NewRecorder()
+		handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message?conventional=true", nil))
+
+		body := rr.Body.String()
+		if !pattern.MatchString(body) {
+			t.Fatalf("message is not a Conventional Commit: %q", body)
+		}
+		if !strings.HasSuffix(body, ": "+strings.TrimRight(mockCommitMessage, "\n")) {
+			t.Errorf("expected the upstream message after the prefix, got %q", body)
+		}
+		scoped = scoped || strings.Contains(body, "(")
+	}
+	if !scoped {
+		t.Errorf("expected some messages to include a scope")
+	}
+
+	// Raw messages remain the default
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message", nil))
+	if rr.Body.String() != strings.TrimRight(mockCommitMessage, "\n") {
+		t.Errorf("expected the raw message by default, got %q", rr.Body.String())
+	}
+}
+
+func TestCommitMessageContentTypeAndTrimmedBody(t *testing.T) {
+	mockUpstream(t, "COMMIT_MESSAGE_URL", serveText("text/plain", "Bump the version\r\n"))
+
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected Content-Type %q, got %q", "text/plain; charset=utf-8", ct)
+	}
+	if body := rr.Body.String(); body != "Bump the version" {
+		t.Errorf("expected the trailing newline trimmed, got %q", body)
+	}
+
+	rr = httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message?format=json", nil))
+	var resp handlers.CommitMessageResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if resp.Message != "Bump the version" {
+		t.Errorf("expected the trimmed message in JSON, got %q", resp.Message)
+	}
+
+	rr = httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message?format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("format=xml: expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestCompositeShapeAndCounts(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.Composite(rr, httptest.NewRequest("GET", "/random?types=user:2,uuid:5,email:3,password", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var resp map[string][]json.RawMessage
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	want := map[string]int{"user": 2, "uuid": 5, "email": 3, "password": 1}
+	if len(resp) != len(want) {
+		t.Errorf("expected keys %v, got %d keys", want, len(resp))
+	}
+	for name, count := range want {
+		if got := len(resp[name]); got != count {
+			t.Errorf("expected %d %s values, got %d", count, name, got)
+		}
+	}
+
+	var user handlers.RandomUser
+	if err := json.Unmarshal(resp["user"][0], &user); err != nil || user.Name.First == "" {
+		t.Errorf("expected a user object, got %s", resp["user"][0])
+	}
+	var email string
+	if err := json.Unmarshal(resp["email"][0], &email); err != nil || !strings.HasSuffix(email, "@example.com") {
+		t.Errorf("expected an example.com email, got %s", resp["email"][0])
+	}
+}
+
+func TestCompositeRejectsInvalidSpecs(t *testing.T) {
+	for _, spec := range []string{"", "unicorn:2", "uuid:0", "uuid:101", "uuid:x", "uuid:2,uuid:3"} {
+		rr := httptest.NewRecorder()
+		handlers.Composite(rr, httptest.NewRequest("GET", "/random?types="+spec, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("types=%q: expected status %d, got %d", spec, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestCompositeNamesStandaloneGenerators(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.Composite(rr, httptest.NewRequest("GET", "/random?types=poem:1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if msg := errorMessage(t, rr); !strings.Contains(msg, "/random-poem") {
+		t.Errorf("expected the error to point at /random-poem, got %q", msg)
+	}
+}
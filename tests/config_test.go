@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestLogConfigReportsEffectiveSettings(t *testing.T) {
+	t.Setenv("PORT", "9090")
+	t.Setenv("UPSTREAM_RETRIES", "4")
+	t.Setenv("USER_URLS", "https://randomuser.me/api,https://mirror.example.com/api")
+	t.Setenv("CHAOS_ENABLED", "true")
+	t.Setenv("READ_TIMEOUT", "7s")
+	t.Setenv("SHUTDOWN_TIMEOUT", "12s")
+	buf := captureLogs(t)
+
+	handlers.LogConfig(handlers.LoadConfig())
+
+	out := buf.String()
+	if n := strings.Count(strings.TrimSpace(out), "\n"); n != 0 {
+		t.Errorf("expected a single log line, got %d lines: %s", n+1, out)
+	}
+	for _, want := range []string{
+		"Configuration at startup: ",
+		`"port":"9090"`,
+		`"upstream_retries":4`,
+		`"user_urls":["https://randomuser.me/api","https://mirror.example.com/api"]`,
+		`"chaos_enabled":true`,
+		`"read_timeout":"7s"`,
+		`"write_timeout":"15s"`,
+		`"shutdown_timeout":"12s"`,
+		`"allowed_hosts":["whatthecommit.com","randomuser.me","loripsum.net"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("configuration log missing %s: %s", want, out)
+		}
+	}
+}
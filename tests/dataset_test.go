@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func mockDatasetUpstreams(t *testing.T) {
+	t.Helper()
+	mockUserUpstream(t)
+	mockCommitUpstream(t)
+	mockLoripsumUpstream(t)
+}
+
+func TestDatasetZip(t *testing.T) {
+	mockDatasetUpstreams(t)
+
+	rr := httptest.NewRecorder()
+	handlers.RandomDataset(rr, httptest.NewRequest("GET", "/random-dataset?format=zip&count=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("expected application/zip, got %q", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a valid zip: %v", err)
+	}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("error opening %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("error reading %s: %v", f.Name, err)
+		}
+		contents[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"users.json", "commits.txt", "lorem.html"} {
+		if contents[name] == "" {
+			t.Errorf("expected non-empty %s in the zip, got files %v", name, zr.File)
+		}
+	}
+	if len(contents) != 3 {
+		t.Errorf("expected 3 files, got %d", len(contents))
+	}
+	if want := "Fixed the thing that broke the other thing\nFixed the thing that broke the other thing\n"; contents["commits.txt"] != want {
+		t.Errorf("expected commits.txt %q, got %q", want, contents["commits.txt"])
+	}
+	if contents["lorem.html"] != mockLoripsumHTML {
+		t.Errorf("expected lorem.html to hold the upstream HTML, got %q", contents["lorem.html"])
+	}
+	var users handlers.UserBatch
+	if err := json.Unmarshal([]byte(contents["users.json"]), &users); err != nil || users.Count != 1 {
+		t.Errorf("expected users.json to hold the user batch, got %v (%v)", contents["users.json"], err)
+	}
+}
+
+func TestDatasetJSON(t *testing.T) {
+	mockDatasetUpstreams(t)
+
+	rr := httptest.NewRecorder()
+	handlers.RandomDataset(rr, httptest.NewRequest("GET", "/random-dataset", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var dataset handlers.Dataset
+	if err := json.NewDecoder(rr.Body).Decode(&dataset); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(dataset.Commits) != 1 || dataset.Lorem == "" || len(dataset.Users.Results) != 1 {
+		t.Errorf("expected 1 user, 1 commit and lorem ipsum, got %+v", dataset)
+	}
+}
+
+func TestDatasetOrdered(t *testing.T) {
+	mockShuffledUserUpstream(t, "Mia", "Ava", "Zoe")
+	commits := []string{"Zap the bug\n", "Add the thing\n", "Merge branch\n"}
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(commits[calls%len(commits)]))
+		calls++
+	})
+	mockLoripsumUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.RandomDataset(rr, httptest.NewRequest("GET", "/random-dataset?count=3&ordered=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var dataset handlers.Dataset
+	if err := json.NewDecoder(rr.Body).Decode(&dataset); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if want := []string{"Add the thing", "Merge branch", "Zap the bug"}; !reflect.DeepEqual(dataset.Commits, want) {
+		t.Errorf("expected sorted commits %v, got %v", want, dataset.Commits)
+	}
+	var firsts []string
+	for _, raw := range dataset.Users.Results {
+		var user handlers.RandomUser
+		if err := json.Unmarshal(raw, &user); err != nil {
+			t.Fatalf("error decoding user: %v", err)
+		}
+		firsts = append(firsts, user.Name.First)
+	}
+	if want := []string{"Ava", "Mia", "Zoe"}; !reflect.DeepEqual(firsts, want) {
+		t.Errorf("expected users sorted by name %v, got %v", want, firsts)
+	}
+}
+
+func TestDatasetTimeoutBoundsAllFetches(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRIES", "0")
+	t.Setenv("COMMIT_TIMEOUT", "2s")
+	t.Setenv("DATASET_TIMEOUT", "150ms")
+	mockUserUpstream(t)
+	mockLoripsumUpstream(t)
+	// Each commit message alone fits its timeout, but ten of them do not fit
+	// the dataset's
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			w.Write([]byte(mockCommitMessage))
+		case <-r.Context().Done():
+		}
+	})
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handlers.RandomDataset(rr, httptest.NewRequest("GET", "/random-dataset?count=10", nil))
+	if rr.Code == http.StatusOK {
+		t.Errorf("expected the dataset to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("took %s, expected to give up after DATASET_TIMEOUT", elapsed)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestEchoReturnsRequest(t *testing.T) {
+	body := `{"hello":"world"}`
+	req := httptest.NewRequest("POST", "/debug/echo?a=1&a=2&b=x&api_key=k3y", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace", "abc123")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("X-API-Key", "k3y")
+
+	rr := httptest.NewRecorder()
+	newMux().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var echo handlers.EchoResponse
+	if err := json.NewDecoder(rr.Body).Decode(&echo); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if echo.Method != "POST" || echo.Path != "/debug/echo" {
+		t.Errorf("expected POST /debug/echo, got %s %s", echo.Method, echo.Path)
+	}
+	if echo.Body != body {
+		t.Errorf("expected body %q, got %q", body, echo.Body)
+	}
+	if got := echo.Query["a"]; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("expected query a=[1 2], got %v", got)
+	}
+	if got := echo.Headers.Get("X-Trace"); got != "abc123" {
+		t.Errorf("expected X-Trace abc123, got %q", got)
+	}
+	if got := echo.Headers.Get("Authorization"); got != "[REDACTED]" {
+		t.Errorf("expected Authorization to be redacted, got %q", got)
+	}
+	if got := echo.Headers.Get("X-API-Key"); got != "[REDACTED]" {
+		t.Errorf("expected X-API-Key to be redacted, got %q", got)
+	}
+	if got := echo.Query.Get("api_key"); got != "[REDACTED]" {
+		t.Errorf("expected api_key to be redacted, got %q", got)
+	}
+}
+
+func TestEchoRejectsOtherMethods(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newMux().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/echo", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
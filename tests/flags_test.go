@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestFlagsKeysAndValues(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.Flags(rr, httptest.NewRequest("GET", "/random-flags?count=50", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var flags map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&flags); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(flags) != 50 {
+		t.Errorf("expected 50 flags, got %d", len(flags))
+	}
+	for key, value := range flags {
+		if !strings.HasPrefix(key, "enable_") && !strings.HasPrefix(key, "use_") {
+			t.Errorf("unexpected flag key %q", key)
+		}
+		switch v := value.(type) {
+		case bool:
+		case float64:
+			if v != float64(int(v)) || v < 0 || v > 100 {
+				t.Errorf("%s: expected a percentage, got %v", key, v)
+			}
+		default:
+			t.Errorf("%s: expected a boolean or percentage, got %T", key, value)
+		}
+	}
+}
+
+func TestFlagsRejectsCountOverCap(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.Flags(rr, httptest.NewRequest("GET", "/random-flags?count=101", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
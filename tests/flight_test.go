@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// fireConcurrently runs n copies of request against h at the same moment and
+// returns the recorded responses
+func fireConcurrently(n int, h http.HandlerFunc, request func() *http.Request) []*httptest.ResponseRecorder {
+	recorders := make([]*httptest.ResponseRecorder, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rr *httptest.ResponseRecorder) {
+			defer wg.Done()
+			<-start
+			h(rr, request())
+		}(recorders[i])
+	}
+	close(start)
+	wg.Wait()
+	return recorders
+}
+
+func TestLoripsumDeduplicatesConcurrentRequests(t *testing.T) {
+	var calls atomic.Int32
+	mockUpstream(t, "LORIPSUM_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(mockLoripsumHTML))
+	})
+
+	recorders := fireConcurrently(10, handlers.Loripsum, func() *http.Request {
+		return httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(`{"number_of_paragraphs":2}`))
+	})
+
+	for _, rr := range recorders {
+		if rr.Code != http.StatusOK || rr.Body.String() != mockLoripsumHTML {
+			t.Errorf("unexpected response: %v %q", rr.Code, rr.Body.String())
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single upstream call, got %d", n)
+	}
+}
+
+func TestUserDeduplicatesConcurrentRequests(t *testing.T) {
+	var calls atomic.Int32
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(mockUserJSON))
+	})
+
+	recorders := fireConcurrently(10, handlers.User, func() *http.Request {
+		return httptest.NewRequest("GET", "/random-user", nil)
+	})
+
+	for _, rr := range recorders {
+		if rr.Code != http.StatusOK {
+			t.Errorf("unexpected status: %v", rr.Code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single upstream call, got %d", n)
+	}
+}
+
+func TestSharedFetchSurvivesFirstCallerCancelling(t *testing.T) {
+	var calls atomic.Int32
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(mockUserJSON))
+	})
+
+	// The first client starts the shared fetch and disconnects while it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil).WithContext(ctx))
+		first <- rr.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+		second <- rr
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if code := <-first; code == http.StatusOK {
+		t.Errorf("expected the cancelled client to get an error")
+	}
+	if rr := <-second; rr.Code != http.StatusOK {
+		t.Errorf("expected the joined client to be served, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single upstream call, got %d", n)
+	}
+}
@@ -10,6 +10,8 @@ import (
 )
 
 func TestCommitMessageHandler(t *testing.T) {
+	mockCommitUpstream(t)
+
 	// Create a request to pass to our handler
 	req, err := http.NewRequest("GET", "/random-commit-message", nil)
 	if err != nil {
@@ -36,6 +38,8 @@ func TestCommitMessageHandler(t *testing.T) {
 }
 
 func TestLoripsumHandler(t *testing.T) {
+	mockLoripsumUpstream(t)
+
 	// Create a test request body
 	body := `{"number_of_paragraphs":2,"paragraph_length":"short"}`
 	
@@ -66,6 +70,8 @@ func TestLoripsumHandler(t *testing.T) {
 }
 
 func TestUserHandler(t *testing.T) {
+	mockUserUpstream(t)
+
 	// Create a request to pass to our handler
 	req, err := http.NewRequest("GET", "/random-user", nil)
 	if err != nil {
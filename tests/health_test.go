@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestHealthAndReadinessAliases(t *testing.T) {
+	tests := []struct {
+		path   string
+		status string
+	}{
+		{"/health", "ok"},
+		{"/healthz", "ok"},
+		{"/ready", "ready"},
+		{"/readyz", "ready"},
+	}
+
+	mux := newMux()
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("GET %s: wrong status code: got %v want %v", tt.path, rr.Code, http.StatusOK)
+			continue
+		}
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Errorf("GET %s: error decoding response: %v", tt.path, err)
+			continue
+		}
+		if body.Status != tt.status {
+			t.Errorf("GET %s: wrong status: got %q want %q", tt.path, body.Status, tt.status)
+		}
+	}
+}
+
+// healthCache fetches /health and returns its cache stats
+func healthCache(t *testing.T) *handlers.CacheStats {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.Health(rr, httptest.NewRequest("GET", "/health", nil))
+
+	var status handlers.HealthStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("error decoding health status: %v", err)
+	}
+	return status.Cache
+}
+
+func TestHealthReportsCacheStats(t *testing.T) {
+	mockUserUpstream(t)
+
+	// The cache is shared with other tests, so compare against a baseline
+	before := healthCache(t)
+	if before == nil {
+		t.Fatalf("expected cache stats while caching is enabled")
+	}
+
+	seed := "health-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?seed="+seed, nil))
+	}
+
+	after := healthCache(t)
+	if hits := after.Hits - before.Hits; hits != 2 {
+		t.Errorf("expected 2 cache hits, got %d", hits)
+	}
+	if misses := after.Misses - before.Misses; misses != 1 {
+		t.Errorf("expected 1 cache miss, got %d", misses)
+	}
+	if after.Entries < 1 {
+		t.Errorf("expected at least one cached entry, got %d", after.Entries)
+	}
+	if want := float64(after.Hits) / float64(after.Hits+after.Misses); after.HitRate != want {
+		t.Errorf("expected hit rate %v, got %v", want, after.HitRate)
+	}
+}
+
+func TestHealthOmitsCacheWhenDisabled(t *testing.T) {
+	t.Setenv("USER_CACHE_TTL", "0s")
+	if stats := healthCache(t); stats != nil {
+		t.Errorf("expected no cache stats with caching disabled, got %+v", stats)
+	}
+}
+
+// healthStatus fetches /health and returns its status code and status field
+func healthStatus(t *testing.T) (int, string) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.Health(rr, httptest.NewRequest("GET", "/health", nil))
+
+	var status handlers.HealthStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("error decoding health status: %v", err)
+	}
+	return rr.Code, status.Status
+}
+
+func TestHealthForceStatusEnv(t *testing.T) {
+	for _, value := range []string{"degraded", "Degraded"} {
+		t.Setenv("HEALTH_FORCE_STATUS", value)
+		code, status := healthStatus(t)
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status code %d, got %d", value, http.StatusServiceUnavailable, code)
+		}
+		if status != "degraded" {
+			t.Errorf("%s: expected status %q, got %q", value, "degraded", status)
+		}
+	}
+}
+
+func TestHealthForceStatusEnvRejectsUnknownValues(t *testing.T) {
+	t.Setenv("HEALTH_FORCE_STATUS", "unhealthy")
+	buf := captureLogs(t)
+
+	if code, status := healthStatus(t); code != http.StatusOK || status != "ok" {
+		t.Errorf("expected 200 ok, got %d %q", code, status)
+	}
+	if !strings.Contains(buf.String(), `unknown HEALTH_FORCE_STATUS="unhealthy"`) {
+		t.Errorf("expected a warning, got logs %q", buf.String())
+	}
+}
+
+func TestAdminHealthDegrade(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.AdminHealthDegrade(rr, httptest.NewRequest("POST", "/admin/health/degrade?degraded=true", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("without ADMIN_TOKEN: expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	t.Cleanup(func() { handlers.SetHealthDegraded(false) })
+	set := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/health/degrade?"+query, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rr := httptest.NewRecorder()
+		handlers.AdminHealthDegrade(rr, req)
+		return rr
+	}
+	degrade := func(degraded bool) {
+		t.Helper()
+		rr := set("degraded=" + strconv.FormatBool(degraded))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("degraded=%t: expected status %d, got %d", degraded, http.StatusOK, rr.Code)
+		}
+		var resp handlers.HealthDegradeResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if resp.Degraded != degraded {
+			t.Errorf("degraded=%t: response reported %t", degraded, resp.Degraded)
+		}
+	}
+
+	// Setting the same state twice, as a retry would, keeps it
+	for i := 0; i < 2; i++ {
+		degrade(true)
+		if code, status := healthStatus(t); code != http.StatusServiceUnavailable || status != "degraded" {
+			t.Errorf("call %d: expected 503 degraded, got %d %q", i+1, code, status)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		degrade(false)
+		if code, status := healthStatus(t); code != http.StatusOK || status != "ok" {
+			t.Errorf("call %d: expected 200 ok, got %d %q", i+1, code, status)
+		}
+	}
+
+	for _, query := range []string{"", "degraded=maybe"} {
+		if rr := set(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHealthReportsFeatures(t *testing.T) {
+	features := func() map[string]bool {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handlers.Health(rr, httptest.NewRequest("GET", "/health", nil))
+		var status handlers.HealthStatus
+		if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+			t.Fatalf("error decoding health status: %v", err)
+		}
+		return status.Features
+	}
+
+	t.Setenv("USER_CACHE_TTL", "0")
+	t.Setenv("LORIPSUM_FALLBACK", "true")
+	t.Setenv("UPSTREAM_RETRIES", "3")
+	t.Setenv("MAX_IN_FLIGHT", "0")
+	want := map[string]bool{"caching": false, "fallback": true, "retries": true, "rate_limit": false, "tls": false}
+	if got := features(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected features %v, got %v", want, got)
+	}
+
+	t.Setenv("USER_CACHE_TTL", "1m")
+	t.Setenv("LORIPSUM_FALLBACK", "")
+	t.Setenv("UPSTREAM_RETRIES", "0")
+	t.Setenv("MAX_IN_FLIGHT", "10")
+	want = map[string]bool{"caching": true, "fallback": false, "retries": false, "rate_limit": true, "tls": false}
+	if got := features(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected features %v, got %v", want, got)
+	}
+}
+
+func TestHealthStreamEvents(t *testing.T) {
+	srv := httptest.NewServer(handlers.LoggingMiddleware(newMux()))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/health/stream?interval=1s", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error opening stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var events []handlers.HealthStatus
+	for len(events) < 2 && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var status handlers.HealthStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			t.Fatalf("error parsing event data %q: %v", data, err)
+		}
+		events = append(events, status)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d: %v", len(events), scanner.Err())
+	}
+	for i, status := range events {
+		if status.Status != "ok" || status.GoVersion == "" {
+			t.Errorf("event %d: unexpected health status %+v", i, status)
+		}
+	}
+	if !events[1].Timestamp.After(events[0].Timestamp) {
+		t.Errorf("expected the second event to be newer than the first")
+	}
+}
+
+func TestHealthStreamRejectsShortIntervals(t *testing.T) {
+	for _, interval := range []string{"10ms", "soon"} {
+		rr := httptest.NewRecorder()
+		handlers.HealthStream(rr, httptest.NewRequest("GET", "/health/stream?interval="+interval, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("interval=%s: expected status %d, got %d", interval, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
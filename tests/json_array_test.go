@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestJSONArrayElementTypes(t *testing.T) {
+	tests := []struct {
+		query string
+		check func(v interface{}) bool
+	}{
+		{"type=int&length=25", func(v interface{}) bool {
+			n, ok := v.(float64)
+			return ok && n == float64(int(n))
+		}},
+		{"type=string&length=25", func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && s != ""
+		}},
+		{"type=object&length=25", func(v interface{}) bool {
+			obj, ok := v.(map[string]interface{})
+			if !ok || len(obj) == 0 {
+				return false
+			}
+			for _, field := range obj {
+				switch field.(type) {
+				case map[string]interface{}, []interface{}:
+					return false
+				}
+			}
+			return true
+		}},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handlers.JSONArray(rr, httptest.NewRequest("GET", "/random-json-array?"+tt.query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v", tt.query, rr.Code, http.StatusOK)
+		}
+
+		var array []interface{}
+		if err := json.NewDecoder(rr.Body).Decode(&array); err != nil {
+			t.Fatalf("%s: error decoding response: %v", tt.query, err)
+		}
+		if len(array) != 25 {
+			t.Errorf("%s: expected 25 elements, got %d", tt.query, len(array))
+		}
+		for i, v := range array {
+			if !tt.check(v) {
+				t.Errorf("%s: element %d has the wrong type: %#v", tt.query, i, v)
+			}
+		}
+	}
+}
+
+func TestJSONArrayRejectsInvalidParams(t *testing.T) {
+	for _, query := range []string{"type=bool", "length=-1", "length=1001", "length=many"} {
+		rr := httptest.NewRecorder()
+		handlers.JSONArray(rr, httptest.NewRequest("GET", "/random-json-array?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// decodeKeys runs path through the case middleware and returns the top-level
+// JSON object
+func decodeKeys(t *testing.T, h http.HandlerFunc, path string) map[string]interface{} {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.CaseMiddleware(h).ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s: wrong status code: got %v want %v", path, rr.Code, http.StatusOK)
+	}
+	var data map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&data); err != nil {
+		t.Fatalf("GET %s: error decoding response: %v", path, err)
+	}
+	return data
+}
+
+func TestCaseMiddlewareCamelHealth(t *testing.T) {
+	data := decodeKeys(t, handlers.Health, "/health?case=camel")
+
+	if _, ok := data["goVersion"]; !ok {
+		t.Errorf("expected goVersion key, got %v", data)
+	}
+	memory, _ := data["memory"].(map[string]interface{})
+	if _, ok := memory["totalAlloc"]; !ok {
+		t.Errorf("expected nested totalAlloc key, got %v", memory)
+	}
+}
+
+func TestCaseMiddlewareSnakeByDefault(t *testing.T) {
+	data := decodeKeys(t, handlers.Health, "/health")
+
+	if _, ok := data["go_version"]; !ok {
+		t.Errorf("expected go_version key, got %v", data)
+	}
+	memory, _ := data["memory"].(map[string]interface{})
+	if _, ok := memory["total_alloc"]; !ok {
+		t.Errorf("expected nested total_alloc key, got %v", memory)
+	}
+}
+
+func TestCaseMiddlewareCamelJSONResponse(t *testing.T) {
+	generator := func(w http.ResponseWriter, r *http.Request) {
+		handlers.RespondWithJSON(w, map[string]interface{}{
+			"results": []map[string]string{{"first_name": "Jane", "last_name": "Doe"}},
+		}, http.StatusOK)
+	}
+
+	data := decodeKeys(t, generator, "/random-user?case=camel")
+
+	results, _ := data["results"].([]interface{})
+	if len(results) != 1 {
+		t.Fatalf("expected one result, got %v", data)
+	}
+	user, _ := results[0].(map[string]interface{})
+	if user["firstName"] != "Jane" || user["lastName"] != "Doe" {
+		t.Errorf("expected camelCase keys inside arrays, got %v", user)
+	}
+}
+
+func TestCaseMiddlewareRejectsUnknownCase(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.CaseMiddleware(http.HandlerFunc(handlers.Health)).ServeHTTP(rr, httptest.NewRequest("GET", "/health?case=kebab", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestNumbersMiddlewareQuotesNumbers(t *testing.T) {
+	h := handlers.NumbersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"test_id":9007199254740993,"items":[{"score":1.5}],"name":"x","ok":true}`))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/?numbers_as_strings=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	want := `{"items":[{"score":"1.5"}],"name":"x","ok":true,"test_id":"9007199254740993"}` + "\n"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("expected quoted numbers:\ngot  %s\nwant %s", got, want)
+	}
+
+	// Numbers are left alone unless requested
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if got := rr.Body.String(); got != `{"test_id":9007199254740993,"items":[{"score":1.5}],"name":"x","ok":true}` {
+		t.Errorf("expected the response untouched, got %s", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/?numbers_as_strings=maybe", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid value, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestNumbersMiddlewareUserTestIDs(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.NumbersMiddleware(http.HandlerFunc(handlers.User)).ServeHTTP(rr, httptest.NewRequest("GET", "/random-user?count=2&with_id=true&numbers_as_strings=true", nil))
+	var batch struct {
+		Results []struct {
+			TestID interface{} `json:"test_id"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&batch); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(batch.Results) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(batch.Results))
+	}
+	for i, user := range batch.Results {
+		if id, ok := user.TestID.(string); !ok || id != strconv.Itoa(i+1) {
+			t.Errorf("expected test_id %q as a string, got %#v", strconv.Itoa(i+1), user.TestID)
+		}
+	}
+}
+
+func TestRewriteMiddlewaresStreamEvents(t *testing.T) {
+	srv := httptest.NewServer(handlers.CaseMiddleware(handlers.NumbersMiddleware(newMux())))
+	t.Cleanup(srv.Close)
+
+	for _, query := range []string{"case=camel", "numbers_as_strings=true", "case=camel&numbers_as_strings=true"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/health/stream?interval=1h&"+query, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			t.Fatalf("%s: error opening stream: %v", query, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusOK, resp.StatusCode)
+		}
+
+		// The first event arrives while the stream is still open, so it was
+		// flushed rather than held back
+		scanner := bufio.NewScanner(resp.Body)
+		gotEvent := false
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "data: ") {
+				gotEvent = true
+				break
+			}
+		}
+		if !gotEvent {
+			t.Errorf("%s: expected an event before the stream closed: %v", query, scanner.Err())
+		}
+		resp.Body.Close()
+		cancel()
+	}
+}
+
+func TestNumbersMiddlewarePassesDownloadsThrough(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	plain := httptest.NewRecorder()
+	handlers.User(plain, httptest.NewRequest("GET", "/random-user?download=true&count=3", nil))
+	rr := httptest.NewRecorder()
+	handlers.NumbersMiddleware(http.HandlerFunc(handlers.User)).ServeHTTP(rr, httptest.NewRequest("GET", "/random-user?download=true&count=3&numbers_as_strings=true", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected Content-Type application/x-ndjson, got %q", ct)
+	}
+	if !rr.Flushed {
+		t.Errorf("expected the download to be flushed as it streamed")
+	}
+	if rr.Body.String() != plain.Body.String() {
+		t.Errorf("expected the NDJSON body unchanged:\n%s\nwant:\n%s", rr.Body.String(), plain.Body.String())
+	}
+}
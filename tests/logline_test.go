@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+var (
+	commonLogPattern   = regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3} - - \[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "(GET|POST|PUT|DELETE|PATCH) /[^ "]* HTTP/1\.1" \d{3} (\d+|-)$`)
+	combinedLogPattern = regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3} - - \[[^\]]+\] "[A-Z]+ /[^ "]* HTTP/1\.1" \d{3} \d+ "[^"]*" "[^"]+"$`)
+)
+
+// getLogLines requests log lines and splits the text response into lines
+func getLogLines(t *testing.T, query string) []string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.LogLines(rr, httptest.NewRequest("GET", "/random-logline?"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	return strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+}
+
+func TestLogLinesApacheFormat(t *testing.T) {
+	lines := getLogLines(t, "format=apache&count=200")
+	if len(lines) != 200 {
+		t.Fatalf("expected 200 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if !commonLogPattern.MatchString(line) {
+			t.Errorf("line does not match the common log format: %q", line)
+		}
+	}
+}
+
+func TestLogLinesNginxFormat(t *testing.T) {
+	for _, line := range getLogLines(t, "format=nginx&count=50") {
+		if !combinedLogPattern.MatchString(line) {
+			t.Errorf("line does not match the combined log format: %q", line)
+		}
+	}
+}
+
+func TestLogLinesJSONFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.LogLines(rr, httptest.NewRequest("GET", "/random-logline?format=json&count=3", nil))
+
+	var lines []handlers.LogLine
+	if err := json.NewDecoder(rr.Body).Decode(&lines); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if line.RemoteAddr == "" || line.Method == "" || !strings.HasPrefix(line.Path, "/") || line.Status == 0 || line.Time.IsZero() {
+			t.Errorf("incomplete log line: %+v", line)
+		}
+	}
+}
+
+func TestLogLinesRejectsUnknownFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.LogLines(rr, httptest.NewRequest("GET", "/random-logline?format=syslog", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
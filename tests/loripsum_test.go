@@ -0,0 +1,309 @@
+package tests
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// postLoripsum sends body to the Loripsum handler
+func postLoripsum(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.Loripsum(rr, httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(body)))
+	return rr
+}
+
+// errorMessage decodes the message from a JSON error response
+func errorMessage(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp handlers.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding error response: %v", err)
+	}
+	return resp.Message
+}
+
+func TestLoripsumDescribesJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"type mismatch", `{"number_of_paragraphs":"two"}`, `field "number_of_paragraphs" must be of type int, got string`},
+		{"truncated", `{"number_of_paragraphs":2`, "request body is truncated"},
+		{"syntax", `{"number_of_paragraphs" 2}`, "malformed JSON at byte offset 25"},
+		{"empty", ``, "request body is empty"},
+	}
+
+	for _, tt := range tests {
+		rr := postLoripsum(t, tt.body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: wrong status code: got %v want %v", tt.name, rr.Code, http.StatusBadRequest)
+			continue
+		}
+		if msg := errorMessage(t, rr); !strings.Contains(msg, tt.want) {
+			t.Errorf("%s: message %q does not contain %q", tt.name, msg, tt.want)
+		}
+	}
+}
+
+// paragraphSentenceCounts splits offline lorem HTML into paragraphs and counts
+// the sentences in each
+func paragraphSentenceCounts(t *testing.T, html string) []int {
+	t.Helper()
+	var counts []int
+	for _, p := range strings.Split(strings.TrimSpace(html), "\n") {
+		if !strings.HasPrefix(p, "<p>") || !strings.HasSuffix(p, "</p>") {
+			t.Fatalf("unexpected paragraph markup: %q", p)
+		}
+		counts = append(counts, strings.Count(p, "."))
+	}
+	return counts
+}
+
+func TestOfflineLoremParagraphLengths(t *testing.T) {
+	ranges := map[string][2]int{
+		"short":    {2, 4},
+		"medium":   {4, 6},
+		"long":     {6, 9},
+		"verylong": {9, 14},
+	}
+
+	for length, bounds := range ranges {
+		rr := postLoripsum(t, `{"offline":true,"number_of_paragraphs":10,"paragraph_length":"`+length+`"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: wrong status code: got %v want %v", length, rr.Code, http.StatusOK)
+		}
+
+		counts := paragraphSentenceCounts(t, rr.Body.String())
+		if len(counts) != 10 {
+			t.Errorf("%s: expected 10 paragraphs, got %d", length, len(counts))
+		}
+		for _, n := range counts {
+			if n < bounds[0] || n > bounds[1] {
+				t.Errorf("%s: paragraph has %d sentences, want %d-%d", length, n, bounds[0], bounds[1])
+			}
+		}
+	}
+}
+
+func TestOfflineLoremConfigurableLengths(t *testing.T) {
+	t.Setenv("OFFLINE_PARAGRAPH_SENTENCES", "short=1-1,verylong=20-22")
+
+	for length, bounds := range map[string][2]int{"short": {1, 1}, "verylong": {20, 22}, "medium": {4, 6}} {
+		rr := postLoripsum(t, `{"offline":true,"number_of_paragraphs":5,"paragraph_length":"`+length+`"}`)
+		for _, n := range paragraphSentenceCounts(t, rr.Body.String()) {
+			if n < bounds[0] || n > bounds[1] {
+				t.Errorf("%s: paragraph has %d sentences, want %d-%d", length, n, bounds[0], bounds[1])
+			}
+		}
+	}
+}
+
+func TestOfflineLoremInvalidConfigUsesDefaults(t *testing.T) {
+	t.Setenv("OFFLINE_PARAGRAPH_SENTENCES", "short=5-2")
+
+	rr := postLoripsum(t, `{"offline":true,"number_of_paragraphs":5,"paragraph_length":"short"}`)
+	for _, n := range paragraphSentenceCounts(t, rr.Body.String()) {
+		if n < 2 || n > 4 {
+			t.Errorf("paragraph has %d sentences, want default 2-4", n)
+		}
+	}
+}
+
+func TestOfflineLoremCappedAtMaxWords(t *testing.T) {
+	t.Setenv("OFFLINE_PARAGRAPH_SENTENCES", "verylong=1000-1000")
+	t.Setenv("OFFLINE_MAX_WORDS", "200")
+	buf := captureLogs(t)
+
+	rr := postLoripsum(t, `{"offline":true,"number_of_paragraphs":10,"paragraph_length":"verylong"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	text := strings.NewReplacer("<p>", " ", "</p>", " ").Replace(rr.Body.String())
+	if got := len(strings.Fields(text)); got != 200 {
+		t.Errorf("expected output capped at 200 words, got %d", got)
+	}
+	if !strings.Contains(buf.String(), "OFFLINE_MAX_WORDS=200") {
+		t.Errorf("expected a truncation warning, got logs %q", buf.String())
+	}
+}
+
+func TestOfflineLoremVariesBetweenCalls(t *testing.T) {
+	body := `{"offline":true,"number_of_paragraphs":3,"paragraph_length":"medium"}`
+	first := postLoripsum(t, body).Body.String()
+	second := postLoripsum(t, body).Body.String()
+
+	if first == second {
+		t.Errorf("expected offline output to vary between calls")
+	}
+}
+
+func TestLoripsumWithStats(t *testing.T) {
+	mockLoripsumUpstream(t)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/random-lorem-ipsum?with_stats=true", strings.NewReader(`{"number_of_paragraphs":2}`))
+	handlers.Loripsum(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", ct)
+	}
+
+	var stats handlers.LoremStats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if stats.HTML != mockLoripsumHTML {
+		t.Errorf("expected the upstream HTML, got %q", stats.HTML)
+	}
+	// The mock has two paragraphs of 8 and 11 words joined by a newline
+	if stats.WordCount != 19 {
+		t.Errorf("expected 19 words, got %d", stats.WordCount)
+	}
+	if stats.CharCount != 127 {
+		t.Errorf("expected 127 characters, got %d", stats.CharCount)
+	}
+	if stats.ParagraphCount != 2 {
+		t.Errorf("expected 2 paragraphs, got %d", stats.ParagraphCount)
+	}
+}
+
+func TestOfflineLoremGzip(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/random-lorem-ipsum?gzip=true", strings.NewReader(`{"offline":true,"number_of_paragraphs":10,"paragraph_length":"verylong"}`))
+	handlers.Loripsum(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if ce := rr.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("expected Content-Encoding gzip, got %q", ce)
+	}
+	compressed := rr.Body.Len()
+
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("error opening gzip body: %v", err)
+	}
+	html, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("error decompressing body: %v", err)
+	}
+	if got := len(paragraphSentenceCounts(t, string(html))); got != 10 {
+		t.Errorf("expected 10 paragraphs after decompressing, got %d", got)
+	}
+	if compressed >= len(html) {
+		t.Errorf("expected the compressed body (%d bytes) to be smaller than the HTML (%d bytes)", compressed, len(html))
+	}
+}
+
+func TestLoripsumSeedIsDeterministicAndOffline(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "LORIPSUM_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(mockLoripsumHTML))
+	})
+
+	generate := func(seed string) string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/random-lorem-ipsum?seed="+seed, strings.NewReader(`{"number_of_paragraphs":3}`))
+		handlers.Loripsum(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		return rr.Body.String()
+	}
+
+	first, second := generate("fixture-1"), generate("fixture-1")
+	if first != second {
+		t.Errorf("expected identical output for identical seeds:\n%s\n%s", first, second)
+	}
+	if other := generate("fixture-2"); other == first {
+		t.Errorf("expected different seeds to produce different output")
+	}
+	if got := len(paragraphSentenceCounts(t, first)); got != 3 {
+		t.Errorf("expected 3 paragraphs, got %d", got)
+	}
+	if calls != 0 {
+		t.Errorf("expected no upstream calls in seeded mode, got %d", calls)
+	}
+}
+
+func TestLoripsumPlainFormat(t *testing.T) {
+	mockUpstream(t, "LORIPSUM_URL", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<p>Lorem <b>ipsum</b> &amp; dolor.</p>\n<p>Sit <a href=\"https://example.com\">amet</a>.</p>\n"))
+	})
+
+	rr := httptest.NewRecorder()
+	handlers.Loripsum(rr, httptest.NewRequest("POST", "/random-lorem-ipsum?format=plain", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain, got %q", ct)
+	}
+	if want := "Lorem ipsum & dolor.\nSit amet.\n"; rr.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, rr.Body.String())
+	}
+}
+
+func TestLoripsumRejectsUnknownFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.Loripsum(rr, httptest.NewRequest("POST", "/random-lorem-ipsum?format=markdown", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestLoripsumFallsBackToOffline(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+	calls := 0
+	mockUpstream(t, "LORIPSUM_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// Without the fallback the upstream failure is passed on
+	rr := postLoripsum(t, `{"number_of_paragraphs":3,"paragraph_length":"short"}`)
+	if rr.Code == http.StatusOK {
+		t.Fatalf("expected an error without the fallback, got status %d", rr.Code)
+	}
+
+	t.Setenv("LORIPSUM_FALLBACK", "true")
+	calls = 0
+	before := metricValue(t, "fallback_served_total")
+	rr = postLoripsum(t, `{"number_of_paragraphs":3,"paragraph_length":"short"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d with the fallback, got %d", http.StatusOK, rr.Code)
+	}
+	if calls == 0 {
+		t.Errorf("expected the upstream to be tried before falling back")
+	}
+	if got := rr.Header().Get("X-Fallback"); got != "true" {
+		t.Errorf("expected X-Fallback: true, got %q", got)
+	}
+	counts := paragraphSentenceCounts(t, rr.Body.String())
+	if len(counts) != 3 {
+		t.Errorf("expected 3 offline paragraphs, got %d", len(counts))
+	}
+	for _, n := range counts {
+		if n < 2 || n > 4 {
+			t.Errorf("expected short paragraphs of 2-4 sentences, got %d", n)
+		}
+	}
+	if got := metricValue(t, "fallback_served_total"); got != before+1 {
+		t.Errorf("expected fallback_served_total to go from %d to %d, got %d", before, before+1, got)
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// metricValue scrapes the /metrics handler and returns the value of name
+func metricValue(t *testing.T, name string) int64 {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.Metrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == name {
+			v, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				t.Fatalf("invalid value for %s: %v", name, err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("metric %s not found in output: %s", name, rr.Body.String())
+	return 0
+}
+
+func TestMetricsExposesCounters(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.Metrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, name := range []string{"upstream_retries_total", "fallback_served_total", "cache_hits_total", "cache_misses_total"} {
+		if !strings.Contains(rr.Body.String(), "# TYPE "+name+" counter") {
+			t.Errorf("metrics output missing %s: %s", name, rr.Body.String())
+		}
+	}
+}
+
+func TestUpstreamRetryIncrementsCounter(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(mockCommitMessage))
+	})
+
+	before := metricValue(t, "upstream_retries_total")
+
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message", nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got := metricValue(t, "upstream_retries_total") - before; got != 1 {
+		t.Errorf("upstream_retries_total increased by %d, want 1", got)
+	}
+}
+
+func TestUpstreamRetryGivesUp(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+	t.Setenv("UPSTREAM_RETRIES", "2")
+	calls := 0
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 upstream calls, got %d", calls)
+	}
+}
+
+func TestResetMetrics(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(mockCommitMessage))
+	})
+
+	handlers.CommitMessage(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-commit-message", nil))
+	before := metricValue(t, "upstream_retries_total")
+	if before == 0 {
+		t.Fatalf("expected upstream_retries_total to be incremented")
+	}
+
+	previous := handlers.ResetMetrics()
+	if previous["upstream_retries_total"] != before {
+		t.Errorf("expected previous upstream_retries_total %d, got %d", before, previous["upstream_retries_total"])
+	}
+	for name := range previous {
+		if got := metricValue(t, name); got != 0 {
+			t.Errorf("expected %s to be 0 after reset, got %d", name, got)
+		}
+	}
+}
+
+func TestMetricsCountsUniqueClients(t *testing.T) {
+	handler := handlers.UniqueClientsMiddleware(100)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	before := metricValue(t, "unique_clients")
+
+	for _, addr := range []string{"198.51.100.7:1234", "198.51.100.8:1234", "198.51.100.7:5678"} {
+		req := httptest.NewRequest("GET", "/random-uuid", nil)
+		req.RemoteAddr = addr
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := metricValue(t, "unique_clients") - before; got != 2 {
+		t.Errorf("unique_clients increased by %d, want 2", got)
+	}
+}
+
+func TestUniqueClientsAreBounded(t *testing.T) {
+	handler := handlers.UniqueClientsMiddleware(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/random-uuid", nil)
+		req.RemoteAddr = "203.0.113." + strconv.Itoa(i) + ":80"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	before := metricValue(t, "unique_clients")
+	req := httptest.NewRequest("GET", "/random-uuid", nil)
+	req.RemoteAddr = "203.0.113.99:80"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got := metricValue(t, "unique_clients"); got != before {
+		t.Errorf("expected a full set to evict rather than grow, went from %d to %d", before, got)
+	}
+}
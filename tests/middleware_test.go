@@ -0,0 +1,475 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// captureLogs redirects the standard logger into a buffer for the duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestLoggingMiddlewareDebugToggle(t *testing.T) {
+	buf := captureLogs(t)
+	t.Cleanup(func() { handlers.SetDebug(false) })
+
+	handler := handlers.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	// Debug on: request detail should be logged
+	handlers.SetDebug(true)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping?x=1", nil))
+	if !strings.Contains(buf.String(), "DEBUG:") {
+		t.Errorf("expected debug line with debug enabled, got: %s", buf.String())
+	}
+
+	// Debug off: only the summary line should be logged
+	buf.Reset()
+	handlers.SetDebug(false)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping?x=1", nil))
+	if strings.Contains(buf.String(), "DEBUG:") {
+		t.Errorf("unexpected debug line with debug disabled, got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "GET /_ping 200") {
+		t.Errorf("expected request summary line, got: %s", buf.String())
+	}
+}
+
+func TestLoggingMiddlewareRedactsAPIKeyQuery(t *testing.T) {
+	buf := captureLogs(t)
+	handlers.SetDebug(true)
+	t.Cleanup(func() { handlers.SetDebug(false) })
+
+	handler := handlers.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-uuid?api_key=k3y&count=2", nil))
+	if out := buf.String(); strings.Contains(out, "k3y") || !strings.Contains(out, "api_key:[[REDACTED]]") {
+		t.Errorf("expected api_key to be redacted, got: %s", out)
+	}
+}
+
+func TestLoggingMiddlewareRedactsHeaders(t *testing.T) {
+	buf := captureLogs(t)
+	handlers.SetDebug(true)
+	t.Cleanup(func() { handlers.SetDebug(false) })
+
+	handler := handlers.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/random-uuid", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("Cookie", "session=s3cret")
+	req.Header.Set("X-API-Key", "s3cret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if out := buf.String(); strings.Contains(out, "s3cret") || !strings.Contains(out, "[REDACTED]") {
+		t.Errorf("expected credential headers to be redacted, got: %s", out)
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	handlers.SetDebug(false)
+	t.Cleanup(func() { handlers.SetDebug(false) })
+
+	if !handlers.ToggleDebug() || !handlers.DebugEnabled() {
+		t.Errorf("expected debug to be enabled after first toggle")
+	}
+	if handlers.ToggleDebug() || handlers.DebugEnabled() {
+		t.Errorf("expected debug to be disabled after second toggle")
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) handlers.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := handlers.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mark("first"), mark("second"), mark("third"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := []string{"first", "second", "third", "handler"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("middlewares ran in wrong order: got %v want %v", order, want)
+	}
+}
+
+func TestMaxInFlightMiddlewareRejectsOverflow(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := handlers.MaxInFlightMiddleware(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	// Saturate the limit with two blocked requests
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+			done <- rr.Code
+		}()
+		<-started
+	}
+
+	// Further requests overflow
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("overflow request: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("in-flight request: got %v want %v", code, http.StatusOK)
+		}
+	}
+
+	// Capacity is available again once the in-flight requests finish
+	go func() { <-started }()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("request after release: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestDebugBodiesMiddleware(t *testing.T) {
+	t.Setenv("DEBUG_BODIES", "true")
+	t.Setenv("DEBUG_BODIES_MAX", "16")
+	logs := captureLogs(t)
+
+	reqBody := `{"number_of_paragraphs":2,"paragraph_length":"short"}`
+	var got string
+	handler := handlers.DebugBodiesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		w.Write([]byte("response body"))
+	}))
+
+	req := httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(reqBody))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got != reqBody {
+		t.Errorf("handler read %q, want the full body %q", got, reqBody)
+	}
+	if rr.Body.String() != "response body" {
+		t.Errorf("client received %q, want %q", rr.Body.String(), "response body")
+	}
+
+	out := logs.String()
+	for _, want := range []string{
+		`"{\"number_of_para"... (37 bytes truncated)`,
+		`"response body"`,
+		"[REDACTED]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected logs to contain %s, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "secret-token") {
+		t.Errorf("expected the Authorization header to be redacted, got %q", out)
+	}
+}
+
+func TestDebugBodiesMiddlewareKeepsBodyLimit(t *testing.T) {
+	t.Setenv("DEBUG_BODIES", "true")
+	t.Setenv("DEBUG_BODIES_MAX", "4")
+	logs := captureLogs(t)
+
+	var readErr error
+	handler := handlers.DebugBodiesMiddleware(handlers.BodyLimitMiddleware(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	})))
+	// No Content-Length, so the limit can only be enforced while reading
+	body := io.MultiReader(strings.NewReader(strings.Repeat("x", 100)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", body))
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("expected the handler to hit the body limit, got %v", readErr)
+	}
+	if want := `"xxxx"... (truncated)`; !strings.Contains(logs.String(), want) {
+		t.Errorf("expected logs to contain %s, got %q", want, logs.String())
+	}
+}
+
+func TestDebugBodiesMiddlewareNegativeMax(t *testing.T) {
+	t.Setenv("DEBUG_BODIES", "true")
+	t.Setenv("DEBUG_BODIES_MAX", "-1")
+	logs := captureLogs(t)
+
+	handler := handlers.DebugBodiesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response body"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("request body")))
+
+	for _, want := range []string{`""... (12 bytes truncated)`, `""... (13 bytes truncated)`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected logs to contain %s, got %q", want, logs.String())
+		}
+	}
+}
+
+func TestDebugBodiesMiddlewareDisabled(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := handlers.DebugBodiesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response body"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("request body")))
+
+	if logs.Len() != 0 {
+		t.Errorf("expected no logs without DEBUG_BODIES, got %q", logs.String())
+	}
+}
+
+func TestURLLengthMiddleware(t *testing.T) {
+	t.Setenv("MAX_URL_LENGTH", "200")
+	t.Setenv("MAX_QUERY_PARAM_LENGTH", "50")
+
+	handler := handlers.URLLengthMiddleware(http.HandlerFunc(handlers.Choice))
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"within limits", "/random-choice?options=a,b,c", http.StatusOK},
+		{"long parameter", "/random-choice?options=" + strings.Repeat("a,", 30) + "b", http.StatusBadRequest},
+		{"long URL", "/random-choice?options=a,b&" + strings.Repeat("x=1&", 50), http.StatusRequestURITooLong},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+	}
+}
+
+func TestErrorRatioMiddlewareWarns(t *testing.T) {
+	logs := captureLogs(t)
+
+	fail := false
+	handler := handlers.ErrorRatioMiddleware(0.5, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "upstream down", http.StatusInternalServerError)
+		}
+	}))
+	serve := func(n int) {
+		for i := 0; i < n; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-user", nil))
+		}
+	}
+
+	// Five failures out of ten is at, not over, the threshold
+	serve(5)
+	fail = true
+	serve(5)
+	if strings.Contains(logs.String(), "WARN") {
+		t.Fatalf("expected no warning at the threshold, got %q", logs.String())
+	}
+
+	serve(3)
+	if got := strings.Count(logs.String(), "WARN: /random-user error ratio 0.60"); got != 1 {
+		t.Errorf("expected one warning once the ratio exceeded the threshold, got %d in %q", got, logs.String())
+	}
+
+	// Staying above the threshold does not repeat the warning
+	serve(5)
+	if got := strings.Count(logs.String(), "WARN"); got != 1 {
+		t.Errorf("expected a single warning while alerting, got %d", got)
+	}
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	t.Setenv("REFERRER_POLICY", "same-origin")
+	cfg := handlers.LoadConfig()
+	handler := handlers.SecurityHeadersMiddleware(cfg.SecurityHeaders)(newMux())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/random-password", nil))
+
+	for name, want := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "same-origin",
+	} {
+		if got := rr.Header().Get(name); got != want {
+			t.Errorf("expected %s %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestSecurityHeadersMiddlewareDisabled(t *testing.T) {
+	t.Setenv("SECURITY_HEADERS", "false")
+	cfg := handlers.LoadConfig()
+	handler := handlers.SecurityHeadersMiddleware(cfg.SecurityHeaders)(newMux())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/random-password", nil))
+
+	if got := rr.Header().Get("X-Frame-Options"); got != "" {
+		t.Errorf("expected no security headers when disabled, got X-Frame-Options %q", got)
+	}
+}
+
+func TestRequestOptionsMiddlewarePopulatesContext(t *testing.T) {
+	var format, seed string
+	var formatOK, seedOK bool
+	handler := handlers.RequestOptionsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format, formatOK = handlers.FormatFromContext(r.Context())
+		seed, seedOK = handlers.SeedFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-user?format=yaml&seed=abc", nil))
+	if !formatOK || format != "yaml" {
+		t.Errorf("expected format %q in context, got %q (present: %v)", "yaml", format, formatOK)
+	}
+	if !seedOK || seed != "abc" {
+		t.Errorf("expected seed %q in context, got %q (present: %v)", "abc", seed, seedOK)
+	}
+}
+
+func TestHandlersReadFormatFromContext(t *testing.T) {
+	// Change the query after the middleware has run; the handler must
+	// follow the format resolved into the context
+	handler := handlers.RequestOptionsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.RawQuery = "format=json"
+		handlers.LogLines(w, r)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/random-logline?format=apache", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected the apache format from the context, got Content-Type %q", ct)
+	}
+}
+
+func TestConfigureLoggerPrefix(t *testing.T) {
+	buf := captureLogs(t)
+	t.Cleanup(func() { handlers.ConfigureLogger("") })
+
+	handlers.ConfigureLogger("[instance-a]")
+	handler := handlers.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatalf("expected log output")
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "[instance-a] ") {
+			t.Errorf("expected line to start with the prefix, got %q", line)
+		}
+	}
+
+	// No prefix by default
+	buf.Reset()
+	handlers.ConfigureLogger("")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_ping", nil))
+	if strings.Contains(buf.String(), "[instance-a]") {
+		t.Errorf("expected no prefix after clearing it, got %q", buf.String())
+	}
+}
+
+func TestLoggingMiddlewareTagsSlowRequests(t *testing.T) {
+	buf := captureLogs(t)
+	t.Setenv("SLOW_REQUEST_THRESHOLD", "20ms")
+
+	handler := handlers.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(40 * time.Millisecond)
+		}
+		w.Write([]byte("OK"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/slow", nil))
+	if got := rr.Header().Get("X-Slow"); got != "true" {
+		t.Errorf("expected X-Slow: true on the slow request, got %q", got)
+	}
+	if !strings.Contains(buf.String(), "WARN: slow request GET /slow took") {
+		t.Errorf("expected a slow request warning, got %q", buf.String())
+	}
+
+	buf.Reset()
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/fast", nil))
+	if got := rr.Header().Get("X-Slow"); got != "" {
+		t.Errorf("expected no X-Slow header on the fast request, got %q", got)
+	}
+	if strings.Contains(buf.String(), "WARN") {
+		t.Errorf("expected no warning for the fast request, got %q", buf.String())
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	handler := handlers.APIKeyMiddleware(newMux())
+	status := func(path string, header string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set("X-API-Key", header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Open while API_KEY is unset
+	if got := status("/random-uuid", ""); got != http.StatusOK {
+		t.Errorf("without API_KEY: expected status %d, got %d", http.StatusOK, got)
+	}
+
+	t.Setenv("API_KEY", "k3y")
+	tests := []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/random-uuid", "k3y", http.StatusOK},
+		{"/random-uuid?api_key=k3y", "", http.StatusOK},
+		{"/random-uuid", "", http.StatusUnauthorized},
+		{"/random-uuid", "wrong", http.StatusUnauthorized},
+		{"/random-uuid?api_key=wrong", "", http.StatusUnauthorized},
+		{"/health", "", http.StatusOK},
+		{"/_ping", "", http.StatusOK},
+		{"/healthz", "", http.StatusOK},
+		{"/ready", "", http.StatusOK},
+		{"/readyz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := status(tt.path, tt.header); got != tt.want {
+			t.Errorf("GET %s with key %q: expected status %d, got %d", tt.path, tt.header, tt.want, got)
+		}
+	}
+}
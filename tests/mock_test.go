@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Canned upstream payloads used by the mock servers
+const (
+	mockCommitMessage = "Fixed the thing that broke the other thing\n"
+	mockLoripsumHTML  = "<p>Lorem ipsum dolor sit amet, consectetur adipiscing elit.</p>\n<p>Quid ergo aliud intellegetur nisi uti ne quae pars naturae neglegatur?</p>\n"
+	mockUserJSON      = `{"results":[{"gender":"female","name":{"title":"Ms","first":"Jane","last":"Doe"},"location":{"street":{"number":1,"name":"Main Street"},"city":"Springfield","state":"Oregon","country":"United States","postcode":12345},"email":"jane.doe@example.com","login":{"uuid":"5f1c6b1e-1d2c-4c1a-9b1e-1a2b3c4d5e6f","username":"bluecat123"},"dob":{"date":"1990-01-01T00:00:00.000Z","age":34},"phone":"(555) 555-0100","cell":"(555) 555-0101","picture":{"large":"https://randomuser.me/api/portraits/women/1.jpg","medium":"https://randomuser.me/api/portraits/med/women/1.jpg","thumbnail":"https://randomuser.me/api/portraits/thumb/women/1.jpg"},"nat":"US"}],"info":{"seed":"abc","results":1,"page":1,"version":"1.4"}}`
+)
+
+// mockUserTemplate is a single randomuser.me result with placeholders for
+// the first name, last name and email
+const mockUserTemplate = `{"gender":"female","name":{"title":"Ms","first":%q,"last":%q},"location":{"street":{"number":1,"name":"Main Street"},"city":"Springfield","state":"Oregon","country":"United States","postcode":12345},"email":%q,"login":{"uuid":"5f1c6b1e-1d2c-4c1a-9b1e-1a2b3c4d5e6f","username":"bluecat123"},"dob":{"date":"1990-01-01T00:00:00.000Z","age":34},"phone":"(555) 555-0100","cell":"(555) 555-0101","picture":{"large":"https://randomuser.me/api/portraits/women/1.jpg","medium":"https://randomuser.me/api/portraits/med/women/1.jpg","thumbnail":"https://randomuser.me/api/portraits/thumb/women/1.jpg"},"nat":"US"}`
+
+// mockUsersJSON builds a randomuser.me response with n distinct users
+func mockUsersJSON(n int) string {
+	results := make([]string, n)
+	for i := range results {
+		first := fmt.Sprintf("User%d", i+1)
+		results[i] = fmt.Sprintf(mockUserTemplate, first, "Doe", strings.ToLower(first)+".doe@example.com")
+	}
+	return fmt.Sprintf(`{"results":[%s],"info":{"seed":"abc","results":%d,"page":1,"version":"1.4"}}`, strings.Join(results, ","), n)
+}
+
+// mockUserBatchUpstream serves as many distinct users as the results
+// parameter asks for
+func mockUserBatchUpstream(t *testing.T) *httptest.Server {
+	return mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.URL.Query().Get("results"))
+		if err != nil {
+			n = 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(mockUsersJSON(n)))
+	})
+}
+
+// mockUpstream starts a test server and points the upstream configured by
+// envKey at it for the duration of the test
+func mockUpstream(t *testing.T, envKey string, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := newMockServer(t, h)
+	t.Setenv(envKey, srv.URL)
+	return srv
+}
+
+// newMockServer starts a test server whose host is on the upstream allowlist
+func newMockServer(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	t.Setenv("UPSTREAM_ALLOWED_HOSTS", "127.0.0.1")
+	return srv
+}
+
+// serveText returns a handler that always responds with body
+func serveText(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}
+}
+
+// mockCommitUpstream serves a fixed commit message
+func mockCommitUpstream(t *testing.T) *httptest.Server {
+	return mockUpstream(t, "COMMIT_MESSAGE_URL", serveText("text/plain", mockCommitMessage))
+}
+
+// mockLoripsumUpstream serves fixed lorem ipsum HTML for any path
+func mockLoripsumUpstream(t *testing.T) *httptest.Server {
+	return mockUpstream(t, "LORIPSUM_URL", serveText("text/html", mockLoripsumHTML))
+}
+
+// mockUserUpstream serves a fixed randomuser.me payload
+func mockUserUpstream(t *testing.T) *httptest.Server {
+	return mockUpstream(t, "USER_URL", serveText("application/json", mockUserJSON))
+}
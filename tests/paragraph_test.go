@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// getParagraph requests /random-paragraph with query and decodes the paragraph
+func getParagraph(t *testing.T, query string) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.RandomParagraph(rr, httptest.NewRequest("GET", "/random-paragraph?"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusOK)
+	}
+	var resp handlers.ParagraphResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("%s: error decoding response: %v", query, err)
+	}
+	return resp.Paragraph
+}
+
+func TestRandomParagraphSentenceCount(t *testing.T) {
+	for _, n := range []int{1, 5, 50} {
+		paragraph := getParagraph(t, "sentences="+strconv.Itoa(n))
+		if got := strings.Count(paragraph, ".") + strings.Count(paragraph, "?") + strings.Count(paragraph, "!"); got != n {
+			t.Errorf("sentences=%d: counted %d sentences in %q", n, got, paragraph)
+		}
+		if !strings.ContainsAny(paragraph[len(paragraph)-1:], ".?!") {
+			t.Errorf("sentences=%d: expected the paragraph to end a sentence, got %q", n, paragraph)
+		}
+	}
+
+	// Five sentences by default
+	paragraph := getParagraph(t, "")
+	if got := strings.Count(paragraph, ".") + strings.Count(paragraph, "?") + strings.Count(paragraph, "!"); got != 5 {
+		t.Errorf("expected 5 sentences by default, counted %d", got)
+	}
+}
+
+func TestRandomParagraphVariety(t *testing.T) {
+	paragraph := getParagraph(t, "sentences=50")
+	for _, mark := range []string{",", ";", "?"} {
+		if !strings.Contains(paragraph, mark) {
+			t.Errorf("expected %q somewhere in 50 sentences, got %q", mark, paragraph)
+		}
+	}
+}
+
+func TestRandomParagraphSeeded(t *testing.T) {
+	if a, b := getParagraph(t, "seed=p1"), getParagraph(t, "seed=p1"); a != b {
+		t.Errorf("expected identical paragraphs for identical seeds:\n%s\n%s", a, b)
+	}
+}
+
+func TestRandomParagraphRejectsInvalidCounts(t *testing.T) {
+	for _, query := range []string{"sentences=0", "sentences=51", "sentences=many"} {
+		rr := httptest.NewRecorder()
+		handlers.RandomParagraph(rr, httptest.NewRequest("GET", "/random-paragraph?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestRandomParagraphCappedAtMaxWords(t *testing.T) {
+	t.Setenv("OFFLINE_MAX_WORDS", "20")
+	buf := captureLogs(t)
+
+	paragraph := getParagraph(t, "sentences=50")
+	if got := len(strings.Fields(paragraph)); got != 20 {
+		t.Errorf("expected paragraph capped at 20 words, got %d: %q", got, paragraph)
+	}
+	if !strings.HasSuffix(paragraph, ".") {
+		t.Errorf("expected the truncated paragraph to end a sentence, got %q", paragraph)
+	}
+	if !strings.Contains(buf.String(), "OFFLINE_MAX_WORDS=20") {
+		t.Errorf("expected a truncation warning, got logs %q", buf.String())
+	}
+}
+
+func TestRandomParagraphInvalidMaxWordsUsesDefault(t *testing.T) {
+	for _, value := range []string{"-5", "0"} {
+		t.Setenv("OFFLINE_MAX_WORDS", value)
+		buf := captureLogs(t)
+
+		paragraph := getParagraph(t, "sentences=5")
+		if got := strings.Count(paragraph, ".") + strings.Count(paragraph, "?") + strings.Count(paragraph, "!"); got != 5 {
+			t.Errorf("OFFLINE_MAX_WORDS=%s: expected 5 whole sentences, counted %d", value, got)
+		}
+		if !strings.Contains(buf.String(), "Invalid OFFLINE_MAX_WORDS") {
+			t.Errorf("OFFLINE_MAX_WORDS=%s: expected a warning, got logs %q", value, buf.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestPasswordHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/random-password?length=20&upper=3&lower=3&digits=3&symbols=3&count=5", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Password).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var resp handlers.PasswordResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(resp.Passwords) != 5 {
+		t.Fatalf("wrong number of passwords: got %d want 5", len(resp.Passwords))
+	}
+
+	classes := map[string]string{
+		"upper":   "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"lower":   "abcdefghijklmnopqrstuvwxyz",
+		"digits":  "0123456789",
+		"symbols": "!@#$%^&*()-_=+[]{}<>?",
+	}
+	for _, password := range resp.Passwords {
+		if len(password) != 20 {
+			t.Errorf("password %q has length %d, want 20", password, len(password))
+		}
+		for name, chars := range classes {
+			n := 0
+			for _, c := range password {
+				if strings.ContainsRune(chars, c) {
+					n++
+				}
+			}
+			if n < 3 {
+				t.Errorf("password %q has %d %s characters, want at least 3", password, n, name)
+			}
+		}
+	}
+}
+
+func TestPasswordHandlerExcludesUnrequestedClasses(t *testing.T) {
+	req := httptest.NewRequest("GET", "/random-password?length=32&upper=0&lower=0&digits=1", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Password).ServeHTTP(rr, req)
+
+	var resp handlers.PasswordResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(resp.Passwords) != 1 {
+		t.Fatalf("wrong number of passwords: got %d want 1", len(resp.Passwords))
+	}
+	if strings.Trim(resp.Passwords[0], "0123456789") != "" {
+		t.Errorf("password %q contains non-digit characters", resp.Passwords[0])
+	}
+}
+
+func TestPasswordHandlerRejectsShortLength(t *testing.T) {
+	req := httptest.NewRequest("GET", "/random-password?length=4&upper=2&lower=2&digits=2", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Password).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "shorter than") {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
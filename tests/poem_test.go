@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestPoemJSONFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.RandomPoem(rr, httptest.NewRequest("GET", "/random-poem?format=json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var poem handlers.Poem
+	if err := json.NewDecoder(rr.Body).Decode(&poem); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if poem.Title == "" {
+		t.Errorf("expected a title")
+	}
+	if n := len(poem.Stanzas); n < 2 || n > 4 {
+		t.Fatalf("expected 2 to 4 stanzas, got %d", n)
+	}
+	for i, stanza := range poem.Stanzas {
+		if len(stanza) != 4 {
+			t.Errorf("stanza %d: expected 4 lines, got %d", i, len(stanza))
+		}
+		for _, line := range stanza {
+			if line == "" || strings.Contains(line, "\n") {
+				t.Errorf("stanza %d: expected single non-empty lines, got %q", i, line)
+			}
+		}
+	}
+}
+
+func TestPoemTextAndHTMLFormats(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.RandomPoem(rr, httptest.NewRequest("GET", "/random-poem", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text by default, got %q", ct)
+	}
+	// The title and each stanza are separated by blank lines
+	if blocks := strings.Split(strings.TrimSpace(rr.Body.String()), "\n\n"); len(blocks) < 3 {
+		t.Errorf("expected a title and at least 2 stanzas, got %q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handlers.RandomPoem(rr, httptest.NewRequest("GET", "/random-poem?format=html", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %q", ct)
+	}
+	if n := strings.Count(rr.Body.String(), "<p>"); n < 2 || n > 4 {
+		t.Errorf("expected one <p> per stanza, got %d in %q", n, rr.Body.String())
+	}
+}
+
+func TestPoemRejectsUnknownFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.RandomPoem(rr, httptest.NewRequest("GET", "/random-poem?format=yaml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
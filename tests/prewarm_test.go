@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestPrewarmContactsEveryUpstream(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]string{}
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[name] = r.Method
+		}
+	}
+
+	mockUpstream(t, "COMMIT_MESSAGE_URL", record("commit"))
+	mockUpstream(t, "LORIPSUM_URL", record("loripsum"))
+	primary := newMockServer(t, record("user-primary"))
+	secondary := newMockServer(t, record("user-secondary"))
+	t.Setenv("USER_URLS", primary.URL+","+secondary.URL)
+
+	handlers.Prewarm(context.Background())
+
+	for _, name := range []string{"commit", "loripsum", "user-primary", "user-secondary"} {
+		if method, ok := seen[name]; !ok {
+			t.Errorf("expected a prewarm request to %s", name)
+		} else if method != http.MethodHead {
+			t.Errorf("expected a HEAD request to %s, got %s", name, method)
+		}
+	}
+}
+
+func TestPrewarmLogsFailures(t *testing.T) {
+	mockCommitUpstream(t)
+	mockLoripsumUpstream(t)
+	t.Setenv("USER_URL", "http://127.0.0.1:1")
+	logs := captureLogs(t)
+
+	handlers.Prewarm(context.Background())
+
+	if !strings.Contains(logs.String(), "Error prewarming upstream http://127.0.0.1:1") {
+		t.Errorf("expected the failed upstream to be logged, got %q", logs.String())
+	}
+}
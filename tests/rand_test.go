@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestSetRandSeedIsReproducible(t *testing.T) {
+	t.Cleanup(func() { handlers.SetRandSeed(time.Now().UnixNano()) })
+
+	sample := func() string {
+		rr := httptest.NewRecorder()
+		handlers.Choice(rr, httptest.NewRequest("GET", "/random-choice?count=50&options=a,b,c,d:2", nil))
+		return rr.Body.String()
+	}
+
+	handlers.SetRandSeed(42)
+	first := sample()
+	handlers.SetRandSeed(42)
+	second := sample()
+
+	if first != second {
+		t.Errorf("expected identical output for identical seeds:\n%s\n%s", first, second)
+	}
+
+	handlers.SetRandSeed(7)
+	if third := sample(); third == first {
+		t.Errorf("expected different output for a different seed")
+	}
+}
@@ -0,0 +1,287 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// newMux returns a mux with every API route registered
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	return mux
+}
+
+func TestMethodMiddlewareRejectsDisallowedMethods(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{"POST", "/random-commit-message", "GET, OPTIONS"},
+		{"DELETE", "/random-user", "GET, OPTIONS"},
+		{"GET", "/random-lorem-ipsum", "POST, OPTIONS"},
+		{"PUT", "/random-password", "GET, OPTIONS"},
+		{"POST", "/health", "GET, OPTIONS"},
+	}
+
+	mux := newMux()
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: wrong status code: got %v want %v", tt.method, tt.path, status, http.StatusMethodNotAllowed)
+		}
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: wrong Allow header: got %q want %q", tt.method, tt.path, allow, tt.allow)
+		}
+	}
+}
+
+func TestMethodMiddlewarePassesAllowedMethods(t *testing.T) {
+	mux := newMux()
+
+	for _, path := range []string{"/random-commit-message", "/random-lorem-ipsum", "/random-user"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("OPTIONS", path, nil))
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("OPTIONS %s: wrong status code: got %v want %v", path, status, http.StatusOK)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/_ping", nil))
+	if body := rr.Body.String(); rr.Code != http.StatusOK || body != "OK" {
+		t.Errorf("GET /_ping: got %v %q", rr.Code, body)
+	}
+}
+
+func TestMethodMiddlewareAnswersOptions(t *testing.T) {
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/random-commit-message", "GET, OPTIONS"},
+		{"/random-lorem-ipsum", "POST, OPTIONS"},
+		{"/validate-card", "POST, OPTIONS"},
+		{"/health", "GET, OPTIONS"},
+		{"/admin/cache/flush", "POST, OPTIONS"},
+	}
+
+	mux := newMux()
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("OPTIONS", tt.path, nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("OPTIONS %s: wrong status code: got %v want %v", tt.path, rr.Code, http.StatusOK)
+		}
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("OPTIONS %s: wrong Allow header: got %q want %q", tt.path, allow, tt.allow)
+		}
+		if methods := rr.Header().Get("Access-Control-Allow-Methods"); methods != tt.allow {
+			t.Errorf("OPTIONS %s: wrong Access-Control-Allow-Methods: got %q want %q", tt.path, methods, tt.allow)
+		}
+		if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+			t.Errorf("OPTIONS %s: wrong Access-Control-Allow-Origin: got %q", tt.path, origin)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("OPTIONS %s: expected no body, got %q", tt.path, rr.Body.String())
+		}
+	}
+}
+
+func TestRouteIndexListsAllRoutes(t *testing.T) {
+	for _, path := range []string{"/", "/routes"} {
+		rr := httptest.NewRecorder()
+		newMux().ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("GET %s: wrong status code: got %v want %v", path, status, http.StatusOK)
+		}
+
+		var listing handlers.RouteListing
+		if err := json.NewDecoder(rr.Body).Decode(&listing); err != nil {
+			t.Fatalf("GET %s: error decoding response: %v", path, err)
+		}
+
+		listed := map[string]handlers.Route{}
+		for _, route := range listing.Routes {
+			listed[route.Path] = route
+		}
+		for _, route := range handlers.Routes() {
+			got, ok := listed[route.Path]
+			if !ok {
+				t.Errorf("GET %s: route %s missing from listing", path, route.Path)
+				continue
+			}
+			if len(got.Methods) == 0 || got.Description == "" {
+				t.Errorf("GET %s: route %s listed without methods or description", path, route.Path)
+			}
+		}
+	}
+}
+
+func TestGeneratorRegistry(t *testing.T) {
+	names := handlers.GeneratorNames()
+	registered := map[string]bool{}
+	for _, name := range names {
+		registered[name] = true
+	}
+
+	// Every generator route is registered under its name, with its handler
+	for _, route := range handlers.Routes() {
+		name, ok := strings.CutPrefix(route.Path, "/random-")
+		if !ok {
+			continue
+		}
+		if !registered[name] {
+			t.Errorf("generator %s missing from GeneratorNames %v", route.Path, names)
+			continue
+		}
+		h, ok := handlers.Generator(name)
+		if !ok || reflect.ValueOf(h).Pointer() != reflect.ValueOf(route.Handler).Pointer() {
+			t.Errorf("generator %q does not dispatch to the %s handler", name, route.Path)
+		}
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("expected sorted generator names, got %v", names)
+	}
+	if _, ok := handlers.Generator("health"); ok {
+		t.Errorf("expected /health not to be registered as a generator")
+	}
+
+	// Dispatch by name
+	h, _ := handlers.Generator("uuid")
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest("GET", "/random-uuid?count=3", nil))
+	var resp handlers.UUIDResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || len(resp.UUIDs) != 3 {
+		t.Errorf("expected 3 UUIDs dispatching uuid by name, got %v (%v)", resp.UUIDs, err)
+	}
+
+	// The route index lists the generators too
+	rr = httptest.NewRecorder()
+	newMux().ServeHTTP(rr, httptest.NewRequest("GET", "/routes", nil))
+	var listing handlers.RouteListing
+	if err := json.NewDecoder(rr.Body).Decode(&listing); err != nil {
+		t.Fatalf("error decoding route listing: %v", err)
+	}
+	if !reflect.DeepEqual(listing.Generators, names) {
+		t.Errorf("expected the listing to name generators %v, got %v", names, listing.Generators)
+	}
+}
+
+func TestRouteIndexUnknownPath(t *testing.T) {
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"} {
+		rr := httptest.NewRecorder()
+		newMux().ServeHTTP(rr, httptest.NewRequest(method, "/no-such-endpoint", nil))
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("%s: wrong status code: got %v want %v", method, status, http.StatusNotFound)
+		}
+	}
+}
+
+func TestRouteIndexIfModifiedSince(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.RouteIndex(rr, httptest.NewRequest("GET", "/routes", nil))
+	lastModified := rr.Header().Get("Last-Modified")
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		t.Fatalf("invalid Last-Modified %q: %v", lastModified, err)
+	}
+
+	tests := []struct {
+		name   string
+		since  string
+		status int
+	}{
+		{"current", lastModified, http.StatusNotModified},
+		{"newer", modified.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"stale", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{"invalid", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/routes", nil)
+		req.Header.Set("If-Modified-Since", tt.since)
+		rr := httptest.NewRecorder()
+		handlers.RouteIndex(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+		if tt.status == http.StatusNotModified && rr.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty body, got %q", tt.name, rr.Body.String())
+		}
+	}
+}
+
+func TestPostProcessorsApplyToGenerators(t *testing.T) {
+	handlers.PostProcessors = append(handlers.PostProcessors, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fork", "custom")
+	})
+	t.Cleanup(func() { handlers.PostProcessors = nil })
+
+	mux := newMux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/random-uuid", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("X-Fork"); got != "custom" {
+		t.Errorf("expected X-Fork header %q, got %q", "custom", got)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	if got := rr.Header().Get("X-Fork"); got != "" {
+		t.Errorf("expected no X-Fork header on /health, got %q", got)
+	}
+}
+
+func TestBodyLimitPerRoute(t *testing.T) {
+	mux := newMux()
+
+	small := `{"number_of_paragraphs":1,"offline":true}`
+	big := `{"number_of_paragraphs":1,"offline":true,"padding":"` + strings.Repeat("x", 5<<10) + `"}`
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(small)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("within limit: expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(big)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized: expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+
+	// Bodies without a declared length are cut off while being decoded
+	req := httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(big))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized without length: expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+
+	// The card route has a tighter limit than the lorem one
+	card := `{"number":"4111 1111 1111 1111","note":"` + strings.Repeat("x", 2<<10) + `"}`
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/validate-card", strings.NewReader(card)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized card: expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// startServer serves h on a local port, returning the server and its URL
+func startServer(t *testing.T, h http.Handler) (*http.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return srv, "http://" + ln.Addr().String()
+}
+
+func TestShutdownForcesCloseAfterTimeout(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	srv, url := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+
+	clientErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		clientErr <- err
+	}()
+	<-entered
+
+	start := time.Now()
+	err := handlers.Shutdown(srv, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "force-closed") {
+		t.Fatalf("expected a forced-close error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected shutdown to give up after the timeout, took %s", elapsed)
+	}
+
+	select {
+	case err := <-clientErr:
+		if err == nil {
+			t.Errorf("expected the hanging request's connection to be closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("hanging request was not force-closed")
+	}
+}
+
+func TestShutdownDrainsIdleServer(t *testing.T) {
+	srv, url := startServer(t, http.HandlerFunc(handlers.Ping))
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := handlers.Shutdown(srv, time.Second); err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestBaseContextMetadataReachesHandlers(t *testing.T) {
+	t.Setenv("DEPLOY_ENV", "staging")
+	md := handlers.LoadMetadata()
+
+	var got handlers.Metadata
+	var ok bool
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = handlers.MetadataFromContext(r.Context())
+	}))
+	srv.Config.BaseContext = func(net.Listener) context.Context {
+		return handlers.WithMetadata(context.Background(), md)
+	}
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	resp.Body.Close()
+
+	if !ok {
+		t.Fatalf("expected metadata in the request context")
+	}
+	if got.Environment != "staging" || got.Hostname != md.Hostname {
+		t.Errorf("unexpected metadata %+v, want %+v", got, md)
+	}
+
+	if _, ok := handlers.MetadataFromContext(context.Background()); ok {
+		t.Errorf("expected no metadata in a bare context")
+	}
+}
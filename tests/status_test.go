@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestRandomStatusClasses(t *testing.T) {
+	tests := []struct {
+		class    string
+		min, max int
+	}{
+		{"2xx", 200, 299},
+		{"4xx", 400, 499},
+		{"5xx", 500, 599},
+		{"", 200, 599},
+	}
+
+	for _, tt := range tests {
+		seen := map[int]bool{}
+		for i := 0; i < 50; i++ {
+			rr := httptest.NewRecorder()
+			handlers.RandomStatus(rr, httptest.NewRequest("GET", "/random-status?class="+tt.class, nil))
+
+			if rr.Code < tt.min || rr.Code > tt.max {
+				t.Fatalf("class %q: status %d outside %d-%d", tt.class, rr.Code, tt.min, tt.max)
+			}
+			if rr.Header().Get("X-Random-Status") != "true" {
+				t.Errorf("class %q: expected X-Random-Status true", tt.class)
+			}
+			seen[rr.Code] = true
+
+			if rr.Code < 400 {
+				var resp handlers.StatusResponse
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.Status != rr.Code {
+					t.Errorf("class %q: expected a body matching status %d, got %+v (%v)", tt.class, rr.Code, resp, err)
+				}
+			} else if msg := errorMessage(t, rr); msg != "Randomly generated "+http.StatusText(rr.Code) {
+				t.Errorf("class %q: unexpected error message %q", tt.class, msg)
+			}
+		}
+		if len(seen) < 2 {
+			t.Errorf("class %q: expected a variety of statuses, got %v", tt.class, seen)
+		}
+	}
+}
+
+func TestRandomStatusRejectsUnknownClass(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.RandomStatus(rr, httptest.NewRequest("GET", "/random-status?class=3xx", nil))
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("X-Random-Status") != "false" {
+		t.Errorf("expected a validation error, got %d with X-Random-Status %q", rr.Code, rr.Header().Get("X-Random-Status"))
+	}
+}
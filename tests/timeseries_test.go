@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// getTimeSeries requests a series and decodes the response
+func getTimeSeries(t *testing.T, query string) []handlers.TimeSeriesPoint {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.TimeSeries(rr, httptest.NewRequest("GET", "/random-timeseries?"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp handlers.TimeSeriesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return resp.Series
+}
+
+func TestTimeSeriesShape(t *testing.T) {
+	for _, trend := range []string{"flat", "up", "down"} {
+		series := getTimeSeries(t, "points=50&start=2024-01-01T00:00:00Z&interval=15m&min=10&max=20&trend="+trend)
+		if len(series) != 50 {
+			t.Fatalf("%s: expected 50 points, got %d", trend, len(series))
+		}
+
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		if !series[0].T.Equal(start) {
+			t.Errorf("%s: expected the series to start at %v, got %v", trend, start, series[0].T)
+		}
+		for i, point := range series {
+			if i > 0 && point.T.Sub(series[i-1].T) != 15*time.Minute {
+				t.Errorf("%s: point %d is not one interval after the previous one", trend, i)
+			}
+			if point.V < 10 || point.V > 20 {
+				t.Errorf("%s: point %d value %v is outside [10, 20]", trend, i, point.V)
+			}
+		}
+	}
+}
+
+func TestTimeSeriesTrend(t *testing.T) {
+	// The drift across the series outweighs the noise at either end
+	up := getTimeSeries(t, "points=100&min=0&max=100&trend=up")
+	if up[len(up)-1].V <= up[0].V {
+		t.Errorf("expected an upward trend, got %v then %v", up[0].V, up[len(up)-1].V)
+	}
+	down := getTimeSeries(t, "points=100&min=0&max=100&trend=down")
+	if down[len(down)-1].V >= down[0].V {
+		t.Errorf("expected a downward trend, got %v then %v", down[0].V, down[len(down)-1].V)
+	}
+}
+
+func TestTimeSeriesRejectsInvalidParams(t *testing.T) {
+	for _, query := range []string{
+		"interval=soon",
+		"interval=-1h",
+		"points=0",
+		"min=5&max=5",
+		"max=ten",
+		"trend=sideways",
+		"start=yesterday",
+	} {
+		rr := httptest.NewRecorder()
+		handlers.TimeSeries(rr, httptest.NewRequest("GET", "/random-timeseries?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
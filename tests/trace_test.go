@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	tp, err := handlers.ParseTraceParent(testTraceparent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tp.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tp.ParentID != "00f067aa0ba902b7" || tp.Flags != "01" {
+		t.Errorf("wrong fields: %+v", tp)
+	}
+	if got := tp.String(); got != testTraceparent {
+		t.Errorf("expected %q to round-trip, got %q", testTraceparent, got)
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	} {
+		if _, err := handlers.ParseTraceParent(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestTracingPropagatesToUpstreamAndLogs(t *testing.T) {
+	var forwarded string
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("traceparent")
+		w.Write([]byte("Fix the thing\n"))
+	})
+	buf := captureLogs(t)
+	handler := handlers.Chain(newMux(), handlers.TracingMiddleware, handlers.LoggingMiddleware)
+
+	req := httptest.NewRequest("GET", "/random-commit-message", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	tp, err := handlers.ParseTraceParent(forwarded)
+	if err != nil {
+		t.Fatalf("expected a valid traceparent upstream, got %q: %v", forwarded, err)
+	}
+	if tp.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the incoming trace ID upstream, got %q", tp.TraceID)
+	}
+	if tp.ParentID == "00f067aa0ba902b7" {
+		t.Errorf("expected the request's own parent ID upstream, got the caller's")
+	}
+	if !strings.Contains(buf.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("expected the trace ID in the logs, got %q", buf.String())
+	}
+}
+
+func TestTracingStartsTraceWhenAbsent(t *testing.T) {
+	var got handlers.TraceParent
+	handler := handlers.TracingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = handlers.TraceFromContext(r.Context())
+	}))
+
+	for _, header := range []string{"", "garbage"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set("traceparent", header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if _, err := handlers.ParseTraceParent(got.String()); err != nil {
+			t.Errorf("traceparent %q: expected a generated trace, got %q: %v", header, got, err)
+		}
+	}
+}
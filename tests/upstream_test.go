@@ -0,0 +1,299 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestScrubHopByHop(t *testing.T) {
+	h := http.Header{}
+	h.Set("Connection", "keep-alive, X-Internal-Hop")
+	h.Set("Keep-Alive", "timeout=5")
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Upgrade", "h2c")
+	h.Set("X-Internal-Hop", "secret")
+	h.Set("Content-Type", "application/json")
+	h.Set("Cache-Control", "no-cache")
+
+	handlers.ScrubHopByHop(h)
+
+	for _, name := range []string{"Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade", "X-Internal-Hop"} {
+		if v := h.Get(name); v != "" {
+			t.Errorf("expected %s to be removed, got %q", name, v)
+		}
+	}
+	for name, want := range map[string]string{"Content-Type": "application/json", "Cache-Control": "no-cache"} {
+		if got := h.Get(name); got != want {
+			t.Errorf("expected %s to be kept as %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestUpstreamDurationHeader(t *testing.T) {
+	mockCommitUpstream(t)
+	mockLoripsumUpstream(t)
+	mockUserUpstream(t)
+
+	requests := map[string]*http.Request{
+		"CommitMessage": httptest.NewRequest("GET", "/random-commit-message", nil),
+		"Loripsum":      httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(`{}`)),
+		"User":          httptest.NewRequest("GET", "/random-user", nil),
+	}
+	handlerFuncs := map[string]http.HandlerFunc{
+		"CommitMessage": handlers.CommitMessage,
+		"Loripsum":      handlers.Loripsum,
+		"User":          handlers.User,
+	}
+
+	for name, req := range requests {
+		rr := httptest.NewRecorder()
+		handlerFuncs[name](rr, req)
+
+		header := rr.Header().Get("X-Upstream-Duration")
+		if header == "" {
+			t.Errorf("%s: missing X-Upstream-Duration header", name)
+			continue
+		}
+		if d, err := time.ParseDuration(header); err != nil || d <= 0 {
+			t.Errorf("%s: X-Upstream-Duration %q is not a positive duration", name, header)
+		}
+	}
+}
+
+func TestUpstreamAllowlistRefusesDisallowedHost(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(mockCommitMessage))
+	})
+	// The mock listens on 127.0.0.1; drop it from the allowlist
+	t.Setenv("UPSTREAM_ALLOWED_HOSTS", "mirror.example.com")
+
+	rr := httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if calls != 0 {
+		t.Errorf("disallowed upstream was contacted %d times", calls)
+	}
+}
+
+func TestUpstreamAllowlistRefusesNonHTTPScheme(t *testing.T) {
+	t.Setenv("USER_URL", "file:///etc/passwd")
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestRetryBudgetStopsRetries(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "COMMIT_MESSAGE_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	t.Setenv("UPSTREAM_RETRIES", "5")
+	t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+	t.Setenv("RETRY_BUDGET", "3")
+	t.Setenv("RETRY_BUDGET_REFILL", "1h")
+
+	handler := handlers.RetryBudgetMiddleware(http.HandlerFunc(handlers.CommitMessage))
+	serve := func(remoteAddr string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/random-commit-message", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	}
+
+	// The budget allows three of the five configured retries
+	serve("192.0.2.10:1234")
+	if calls != 4 {
+		t.Errorf("expected 4 upstream calls with the budget available, got %d", calls)
+	}
+
+	// Once exhausted the error surfaces without retrying
+	calls = 0
+	serve("192.0.2.10:5678")
+	if calls != 1 {
+		t.Errorf("expected 1 upstream call with the budget exhausted, got %d", calls)
+	}
+
+	// Other clients keep their own budget
+	calls = 0
+	serve("192.0.2.11:1234")
+	if calls != 4 {
+		t.Errorf("expected 4 upstream calls for another client, got %d", calls)
+	}
+}
+
+func TestUpstreamTransportPooling(t *testing.T) {
+	t.Setenv("UPSTREAM_MAX_IDLE_CONNS", "250")
+	t.Setenv("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", "50")
+	t.Setenv("UPSTREAM_IDLE_CONN_TIMEOUT", "2m")
+
+	transport := handlers.NewUpstreamTransport()
+	if transport.MaxIdleConns != 250 {
+		t.Errorf("expected MaxIdleConns 250, got %d", transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 50 {
+		t.Errorf("expected MaxIdleConnsPerHost 50, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 2*time.Minute {
+		t.Errorf("expected IdleConnTimeout 2m, got %s", transport.IdleConnTimeout)
+	}
+	if transport.Proxy == nil {
+		t.Errorf("expected the default transport's proxy settings to be kept")
+	}
+}
+
+func TestHandlersUsePerUpstreamTimeouts(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRIES", "0")
+
+	// slow answers after 200ms unless the request is cancelled first
+	slow := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(200 * time.Millisecond):
+				w.Write([]byte(body))
+			case <-r.Context().Done():
+			}
+		}
+	}
+	mockUpstream(t, "USER_URL", slow(mockUserJSON))
+	mockUpstream(t, "LORIPSUM_URL", slow(mockLoripsumHTML))
+	mockUpstream(t, "COMMIT_MESSAGE_URL", slow(mockCommitMessage))
+
+	tests := []struct {
+		envKey string
+		call   func() int
+	}{
+		{"USER_TIMEOUT", func() int {
+			rr := httptest.NewRecorder()
+			handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+			return rr.Code
+		}},
+		{"LORIPSUM_TIMEOUT", func() int {
+			rr := httptest.NewRecorder()
+			handlers.Loripsum(rr, httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(`{}`)))
+			return rr.Code
+		}},
+		{"COMMIT_TIMEOUT", func() int {
+			rr := httptest.NewRecorder()
+			handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message", nil))
+			return rr.Code
+		}},
+	}
+
+	for _, tt := range tests {
+		// A short global timeout is overridden by the upstream's own
+		t.Setenv("UPSTREAM_TIMEOUT", "20ms")
+		t.Setenv(tt.envKey, "2s")
+		if code := tt.call(); code != http.StatusOK {
+			t.Errorf("%s=2s: expected status %d, got %d", tt.envKey, http.StatusOK, code)
+		}
+
+		// ...and a short upstream timeout wins over a generous global one
+		t.Setenv("UPSTREAM_TIMEOUT", "2s")
+		t.Setenv(tt.envKey, "20ms")
+		start := time.Now()
+		if code := tt.call(); code == http.StatusOK {
+			t.Errorf("%s=20ms: expected the request to time out", tt.envKey)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("%s=20ms: took %s, expected to give up after the timeout", tt.envKey, elapsed)
+		}
+		t.Setenv(tt.envKey, "")
+	}
+}
+
+func TestUpstreamConcurrencyIsPerHost(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var userCalls atomic.Int32
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		userCalls.Add(1)
+		started <- struct{}{}
+		<-release
+		w.Write([]byte(mockUserJSON))
+	})
+	commit := newMockServer(t, serveText("text/plain", mockCommitMessage))
+	// Reach the commit upstream by another name so it counts as another host
+	t.Setenv("COMMIT_MESSAGE_URL", strings.Replace(commit.URL, "127.0.0.1", "localhost", 1))
+	t.Setenv("UPSTREAM_ALLOWED_HOSTS", "127.0.0.1,localhost")
+	t.Setenv("USER_CONCURRENCY", "1")
+	t.Setenv("UPSTREAM_RETRIES", "0")
+
+	// Saturate the user host with one request held open by the upstream
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=1", nil))
+		done <- rr.Code
+	}()
+	<-started
+
+	// A second user request waits for the slot and times out
+	t.Setenv("USER_TIMEOUT", "50ms")
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=2", nil))
+	if rr.Code == http.StatusOK {
+		t.Errorf("expected the second user request to time out waiting for the host")
+	}
+	if got := userCalls.Load(); got != 1 {
+		t.Errorf("expected 1 request at the user upstream, got %d", got)
+	}
+
+	// The commit host is unaffected
+	rr = httptest.NewRecorder()
+	handlers.CommitMessage(rr, httptest.NewRequest("GET", "/random-commit-message", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the commit host to be unaffected, got status %d", rr.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected the held user request to succeed, got status %d", code)
+	}
+}
+
+func TestUpstreamURLsLoggedAtDebug(t *testing.T) {
+	loripsum := mockLoripsumUpstream(t)
+	user := mockUserUpstream(t)
+	buf := captureLogs(t)
+	t.Cleanup(func() { handlers.SetDebug(false) })
+
+	call := func() {
+		handlers.Loripsum(httptest.NewRecorder(), httptest.NewRequest("POST", "/random-lorem-ipsum", strings.NewReader(`{"number_of_paragraphs":2,"code":true}`)))
+		handlers.User(httptest.NewRecorder(), httptest.NewRequest("GET", "/random-user?nat=fr", nil))
+	}
+
+	handlers.SetDebug(true)
+	call()
+	for _, want := range []string{loripsum.URL + "/api/2/code", user.URL + "?nat=fr"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected upstream URL %s in the debug logs, got: %s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	handlers.SetDebug(false)
+	call()
+	if strings.Contains(buf.String(), loripsum.URL) || strings.Contains(buf.String(), user.URL) {
+		t.Errorf("expected no upstream URLs logged with debug off, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,1232 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestUserFailsOverToNextUpstream(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+
+	primaryCalls := 0
+	primary := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	mirror := newMockServer(t, serveText("application/json", mockUserJSON))
+
+	t.Setenv("USER_URLS", primary.URL+","+mirror.URL)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if users := decodeUsers(t, rr); len(users) != 1 || users[0]["email"] != "jane.doe@example.com" {
+		t.Errorf("handler returned unexpected users: %v", users)
+	}
+	if primaryCalls != 3 {
+		t.Errorf("expected primary to be tried with retries (3 calls), got %d", primaryCalls)
+	}
+}
+
+func TestUserFailoverAllUpstreamsDown(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRIES", "0")
+
+	down := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	})
+
+	t.Setenv("USER_URLS", down.URL+","+down.URL)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestUserVCard(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=vcard", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/vcard") {
+		t.Errorf("handler returned wrong content type: %v", ct)
+	}
+
+	body := rr.Body.String()
+	if !strings.HasPrefix(body, "BEGIN:VCARD\r\nVERSION:3.0\r\n") || !strings.HasSuffix(body, "END:VCARD\r\n") {
+		t.Errorf("vCard is not properly delimited: %q", body)
+	}
+	for _, field := range []string{
+		"N:Doe;Jane;;Ms;",
+		"FN:Jane Doe",
+		"EMAIL;TYPE=INTERNET:jane.doe@example.com",
+		"TEL;TYPE=CELL:(555) 555-0101",
+		"ADR;TYPE=HOME:;;1 Main Street;Springfield;Oregon;12345;United States",
+	} {
+		if !strings.Contains(body, field+"\r\n") {
+			t.Errorf("vCard missing %q: %q", field, body)
+		}
+	}
+}
+
+func TestUserVCardBatch(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=vcard&count=3", nil))
+
+	body := rr.Body.String()
+	if n := strings.Count(body, "BEGIN:VCARD"); n != 3 {
+		t.Errorf("expected 3 vCards, got %d: %q", n, body)
+	}
+	if n := strings.Count(body, "END:VCARD"); n != 3 {
+		t.Errorf("expected 3 vCard terminators, got %d", n)
+	}
+	if !strings.Contains(body, "FN:User3 Doe") {
+		t.Errorf("expected the third user in the batch: %q", body)
+	}
+}
+
+func TestUserRejectsUnknownFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=pdf", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUserNDJSON(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=ndjson&count=4", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("handler returned wrong content type: %v", ct)
+	}
+	lines := strings.Split(strings.TrimRight(rr.Body.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), rr.Body.String())
+	}
+}
+
+func TestUserDownloadOutlivesWriteTimeout(t *testing.T) {
+	t.Setenv("USER_DOWNLOAD_MAX", "1000")
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		n, _ := strconv.Atoi(r.URL.Query().Get("results"))
+		w.Write([]byte(mockUsersJSON(n)))
+	})
+
+	// Three pages of 100ms each run well past the 150ms write timeout
+	srv := httptest.NewUnstartedServer(newMux())
+	srv.Config.WriteTimeout = 150 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/random-user?download=true&count=250")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("download was cut off: %v", err)
+	}
+	if lines := strings.Count(string(body), "\n"); lines != 250 {
+		t.Errorf("expected 250 lines, got %d", lines)
+	}
+}
+
+func TestUserDownloadRejectsOtherFormats(t *testing.T) {
+	mockUserUpstream(t)
+
+	for _, format := range []string{"json", "sql", "vcard", "yaml", "html"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?download=true&format="+format, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("format=%s: expected status %d, got %d", format, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?download=true&format=ndjson", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("format=ndjson: expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestUserDownloadStreamsPages(t *testing.T) {
+	t.Setenv("USER_DOWNLOAD_MAX", "1000")
+	var pages []string
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("results"))
+		n, _ := strconv.Atoi(r.URL.Query().Get("results"))
+		w.Write([]byte(mockUsersJSON(n)))
+	})
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?download=true&count=250", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="users.ndjson"` {
+		t.Errorf("handler returned wrong Content-Disposition: %q", cd)
+	}
+
+	lines := strings.Split(strings.TrimRight(rr.Body.String(), "\n"), "\n")
+	if len(lines) != 250 {
+		t.Fatalf("expected 250 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var user handlers.RandomUser
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v", i+1, err)
+		}
+	}
+	if strings.Join(pages, ",") != "100,100,50" {
+		t.Errorf("expected pages of 100,100,50, got %v", pages)
+	}
+}
+
+func TestUserDownloadRespectsMax(t *testing.T) {
+	t.Setenv("USER_DOWNLOAD_MAX", "10")
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?download=true&count=11", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUserCachesSeededRequests(t *testing.T) {
+	calls := 0
+	var seeds []string
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		seeds = append(seeds, r.URL.Query().Get("seed"))
+		w.Write([]byte(mockUserJSON))
+	})
+
+	hitsBefore := metricValue(t, "cache_hits_total")
+
+	first := httptest.NewRecorder()
+	handlers.User(first, httptest.NewRequest("GET", "/random-user?seed=fixture", nil))
+	second := httptest.NewRecorder()
+	handlers.User(second, httptest.NewRequest("GET", "/random-user?seed=fixture", nil))
+
+	if got := first.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("first request X-Cache: got %q want MISS", got)
+	}
+	if got := second.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("second request X-Cache: got %q want HIT", got)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("cached body differs from original")
+	}
+	if got := second.Header().Get("X-Upstream-Duration"); got != "0s" {
+		t.Errorf("cache hit X-Upstream-Duration: got %q want 0s", got)
+	}
+	if calls != 1 {
+		t.Errorf("expected a single upstream call, got %d", calls)
+	}
+	if len(seeds) == 0 || seeds[0] != "fixture" {
+		t.Errorf("expected seed to be forwarded upstream, got %v", seeds)
+	}
+	if got := metricValue(t, "cache_hits_total") - hitsBefore; got != 1 {
+		t.Errorf("cache_hits_total increased by %d, want 1", got)
+	}
+}
+
+func TestUserDoesNotCacheUnseededRequests(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(mockUserJSON))
+	})
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+		if got := rr.Header().Get("X-Cache"); got != "" {
+			t.Errorf("unseeded request should not carry X-Cache, got %q", got)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", calls)
+	}
+}
+
+func TestUserCacheDisabledByNonPositiveMaxEntries(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(mockUserJSON))
+	})
+
+	for _, max := range []string{"0", "-1"} {
+		t.Setenv("USER_CACHE_MAX_ENTRIES", max)
+		calls = 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 2; i++ {
+				rr := httptest.NewRecorder()
+				handlers.User(rr, httptest.NewRequest("GET", "/random-user?seed=no-cache"+max, nil))
+				if got := rr.Header().Get("X-Cache"); got != "" {
+					t.Errorf("USER_CACHE_MAX_ENTRIES=%s: expected no X-Cache, got %q", max, got)
+				}
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("USER_CACHE_MAX_ENTRIES=%s: seeded requests hung", max)
+		}
+		if calls != 2 {
+			t.Errorf("USER_CACHE_MAX_ENTRIES=%s: expected 2 upstream calls, got %d", max, calls)
+		}
+	}
+}
+
+// mockPagedUserUpstream emulates randomuser.me paging: under a seed, page N
+// of size R holds users (N-1)*R .. N*R-1
+func mockPagedUserUpstream(t *testing.T) {
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		size, _ := strconv.Atoi(q.Get("results"))
+		results := make([]string, size)
+		for i := range results {
+			id := q.Get("seed") + "-" + strconv.Itoa((page-1)*size+i)
+			results[i] = `{"login":{"uuid":"` + id + `"}}`
+		}
+		w.Write([]byte(`{"results":[` + strings.Join(results, ",") + `]}`))
+	})
+}
+
+func TestUserPagesCoverFullSetWithoutOverlap(t *testing.T) {
+	mockPagedUserUpstream(t)
+
+	seen := map[string]bool{}
+	page := 1
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("pagination did not terminate")
+		}
+
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?seed=pages&page_size=40&total=100&page="+strconv.Itoa(page), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("page %d: wrong status code: got %v want %v: %s", page, rr.Code, http.StatusOK, rr.Body.String())
+		}
+
+		var resp struct {
+			Results []handlers.RandomUser `json:"results"`
+			Seed    string                `json:"seed"`
+			handlers.Pagination
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("page %d: error decoding response: %v", page, err)
+		}
+		for _, user := range resp.Results {
+			if seen[user.Login.UUID] {
+				t.Errorf("page %d: user %s already seen on an earlier page", page, user.Login.UUID)
+			}
+			seen[user.Login.UUID] = true
+		}
+
+		if resp.NextPage == nil {
+			break
+		}
+		page = *resp.NextPage
+	}
+
+	if len(seen) != 100 {
+		t.Errorf("expected pages to cover 100 users, got %d", len(seen))
+	}
+	for i := 0; i < 100; i++ {
+		if !seen["pages-"+strconv.Itoa(i)] {
+			t.Errorf("user %d missing from pages", i)
+		}
+	}
+}
+
+func TestUserPageCachesOnlyClientSeeds(t *testing.T) {
+	mockPagedUserUpstream(t)
+
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{"page_size=5&total=10", ""},
+		{"page_size=5&total=10&seed=cached-pages", "MISS"},
+		{"page_size=5&total=10&seed=cached-pages", "HIT"},
+	} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+tt.query, nil))
+		if got := rr.Header().Get("X-Cache"); got != tt.want {
+			t.Errorf("%s: X-Cache got %q want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestUserPageRejectsOutOfRange(t *testing.T) {
+	for _, query := range []string{"page=4&page_size=40&total=100", "page=0", "page=1&page_size=500", "page=1&total=1000000", "page=9223372036854775807&page_size=2", "page=4611686018427387905&page_size=4"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// decodeUsers decodes the results of a JSON user response into generic maps
+func decodeUsers(t *testing.T, rr *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	var resp struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return resp.Results
+}
+
+func TestUserWithID(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=5&with_id=true", nil))
+
+	users := decodeUsers(t, rr)
+	if len(users) != 5 {
+		t.Fatalf("expected 5 users, got %d", len(users))
+	}
+	seen := map[float64]bool{}
+	for i, user := range users {
+		id, ok := user["test_id"].(float64)
+		if !ok {
+			t.Fatalf("user %d missing numeric test_id: %v", i, user)
+		}
+		if seen[id] {
+			t.Errorf("duplicate test_id %v", id)
+		}
+		seen[id] = true
+		if id != float64(i+1) {
+			t.Errorf("user %d has test_id %v, want %d", i, id, i+1)
+		}
+	}
+}
+
+func TestUserWithIDContinuesAcrossPages(t *testing.T) {
+	mockPagedUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?seed=ids&page=2&page_size=40&total=100&with_id=true", nil))
+
+	users := decodeUsers(t, rr)
+	if len(users) != 40 {
+		t.Fatalf("expected 40 users, got %d", len(users))
+	}
+	if first, last := users[0]["test_id"], users[39]["test_id"]; first != float64(41) || last != float64(80) {
+		t.Errorf("expected test_ids 41..80 on page 2, got %v..%v", first, last)
+	}
+}
+
+func TestUserWithoutIDLeavesBodyUntouched(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+
+	if strings.Contains(rr.Body.String(), "test_id") {
+		t.Errorf("unexpected test_id without with_id=true")
+	}
+}
+
+func TestUserFlatten(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?flatten=true&with_id=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	users := decodeUsers(t, rr)
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	user := users[0]
+
+	want := map[string]interface{}{
+		"name_first":           "Jane",
+		"name_last":            "Doe",
+		"location_city":        "Springfield",
+		"location_street_name": "Main Street",
+		"dob_age":              float64(34),
+		"email":                "jane.doe@example.com",
+		"test_id":              float64(1),
+	}
+	for key, value := range want {
+		if user[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, user[key])
+		}
+	}
+	for key, value := range user {
+		if _, nested := value.(map[string]interface{}); nested {
+			t.Errorf("expected %s to be flattened, got an object", key)
+		}
+	}
+	if _, ok := user["name"]; ok {
+		t.Errorf("expected the nested name object to be removed")
+	}
+}
+
+func TestUserFlattenRejectsVCard(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?flatten=true&format=vcard", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestUserPictureSize(t *testing.T) {
+	mockUserUpstream(t)
+
+	want := map[string]string{
+		"thumbnail": "https://randomuser.me/api/portraits/thumb/women/1.jpg",
+		"medium":    "https://randomuser.me/api/portraits/med/women/1.jpg",
+		"large":     "https://randomuser.me/api/portraits/women/1.jpg",
+	}
+	for size, url := range want {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?picture_size="+size, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v: %s", size, rr.Code, http.StatusOK, rr.Body.String())
+		}
+		users := decodeUsers(t, rr)
+		if len(users) != 1 {
+			t.Fatalf("%s: expected 1 user, got %d", size, len(users))
+		}
+		if users[0]["picture"] != url {
+			t.Errorf("%s: expected picture %q, got %v", size, url, users[0]["picture"])
+		}
+	}
+}
+
+func TestUserPictureSizeRejectsUnknownSize(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?picture_size=huge", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestUserStrictRejectsUnknownFields(t *testing.T) {
+	drifted := strings.Replace(mockUserJSON, `"nat":"US"`, `"nat":"US","pronouns":"she/her"`, 1)
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(drifted))
+	})
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?strict=true", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, rr.Code)
+	}
+	if msg := errorMessage(t, rr); !strings.Contains(msg, `"pronouns"`) {
+		t.Errorf("expected the error to name the unexpected field, got %q", msg)
+	}
+
+	// Without strict mode the extra field passes through
+	rr = httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d without strict mode, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestUserStrictAcceptsKnownSchema(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?strict=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func TestUserCreatedWithin(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	for _, tt := range []struct {
+		window string
+		span   time.Duration
+	}{{"30d", 30 * 24 * time.Hour}, {"12h", 12 * time.Hour}} {
+		start := time.Now().Truncate(time.Second)
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=20&created_within="+tt.window, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v: %s", tt.window, rr.Code, http.StatusOK, rr.Body.String())
+		}
+
+		users := decodeUsers(t, rr)
+		if len(users) == 0 {
+			t.Fatalf("%s: expected users", tt.window)
+		}
+		end := time.Now()
+		for i, user := range users {
+			raw, _ := user["created_at"].(string)
+			created, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				t.Fatalf("%s: user %d has invalid created_at %q: %v", tt.window, i, raw, err)
+			}
+			if created.After(end) || created.Before(start.Add(-tt.span)) {
+				t.Errorf("%s: user %d created_at %s is outside the window", tt.window, i, created)
+			}
+		}
+	}
+}
+
+func TestUserCreatedWithinRejectsInvalidWindow(t *testing.T) {
+	for _, window := range []string{"30", "d", "0d", "-1h", "2w", "1.5d"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?created_within="+window, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", window, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestUserProjection(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?project=name.first,location.city,email,missing.field", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	users := decodeUsers(t, rr)
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	want := map[string]interface{}{
+		"name":     map[string]interface{}{"first": "Jane"},
+		"location": map[string]interface{}{"city": "Springfield"},
+		"email":    "jane.doe@example.com",
+	}
+	if !reflect.DeepEqual(users[0], want) {
+		t.Errorf("expected projection %v, got %v", want, users[0])
+	}
+}
+
+func TestUserProjectionStrictRejectsUnknownPath(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?project=email,name.middle&project_strict=true", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if msg := errorMessage(t, rr); !strings.Contains(msg, "name.middle") {
+		t.Errorf("expected the error to name the unknown path, got %q", msg)
+	}
+}
+
+func TestUserDicebearAvatarsAreReproducible(t *testing.T) {
+	mockUserUpstream(t)
+
+	pictures := func(seed string) map[string]interface{} {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?avatar=dicebear&seed="+seed, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		users := decodeUsers(t, rr)
+		if len(users) != 1 {
+			t.Fatalf("expected 1 user, got %d", len(users))
+		}
+		picture, _ := users[0]["picture"].(map[string]interface{})
+		return picture
+	}
+
+	first, second := pictures("fixture"), pictures("fixture")
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected identical avatars for identical seeds, got %v and %v", first, second)
+	}
+	for _, field := range []string{"large", "medium", "thumbnail"} {
+		u, _ := first[field].(string)
+		if !strings.HasPrefix(u, "https://api.dicebear.com/") || !strings.Contains(u, "seed=fixture") {
+			t.Errorf("expected a seeded avatar URL in %s, got %q", field, u)
+		}
+	}
+	if other := pictures("another"); reflect.DeepEqual(first, other) {
+		t.Errorf("expected different seeds to yield different avatars")
+	}
+}
+
+func TestUserDicebearAvatarRequiresSeed(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?avatar=dicebear", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestUserFemaleRatio(t *testing.T) {
+	// Serve users of the requested gender, as the upstream does
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.URL.Query().Get("results"))
+		if err != nil {
+			n = 1
+		}
+		gender := r.URL.Query().Get("gender")
+		if gender == "" {
+			gender = "female"
+		}
+		body := strings.ReplaceAll(mockUsersJSON(n), `"gender":"female"`, `"gender":"`+gender+`"`)
+		w.Write([]byte(body))
+	})
+
+	for _, ratio := range []float64{0, 0.3, 0.7, 1} {
+		query := "/random-user?count=100&female_ratio=" + strconv.FormatFloat(ratio, 'f', -1, 64)
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v: %s", query, rr.Code, http.StatusOK, rr.Body.String())
+		}
+
+		users := decodeUsers(t, rr)
+		if len(users) != 100 {
+			t.Fatalf("%s: expected 100 users, got %d", query, len(users))
+		}
+		females := 0
+		for _, user := range users {
+			if user["gender"] == "female" {
+				females++
+			}
+		}
+		if observed := float64(females) / float64(len(users)); math.Abs(observed-ratio) > 0.01 {
+			t.Errorf("%s: observed female ratio %.2f, want %.2f", query, observed, ratio)
+		}
+	}
+}
+
+func TestUserFemaleRatioValidation(t *testing.T) {
+	for _, query := range []string{"female_ratio=1.5", "female_ratio=-0.1", "female_ratio=most", "female_ratio=0.5&gender=male"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestUserForwardsNationality(t *testing.T) {
+	var nats []string
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		nats = append(nats, r.URL.Query().Get("nat"))
+		w.Write([]byte(mockUserJSON))
+	})
+
+	for _, query := range []string{"nat=fr", "nat=FR&download=true", "nat=fr&page=1&page_size=1&total=1"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v: %s", query, rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
+
+	if len(nats) != 3 {
+		t.Fatalf("expected 3 upstream requests, got %d", len(nats))
+	}
+	for i, nat := range nats {
+		if nat != "fr" {
+			t.Errorf("request %d: expected upstream nat=fr, got %q", i, nat)
+		}
+	}
+}
+
+func TestUserRejectsUnknownNationality(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?nat=fr,xx", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestUserSQLFormat(t *testing.T) {
+	mockUpstream(t, "USER_URL", serveText("application/json", fmt.Sprintf(`{"results":[%s]}`,
+		fmt.Sprintf(mockUserTemplate, "Siobhán", "O'Brien", "siobhan.obrien@example.com"))))
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=sql&table=test_users", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	want := "INSERT INTO test_users (first,last,email) VALUES ('Siobhán', 'O''Brien', 'siobhan.obrien@example.com');\n"
+	if body := rr.Body.String(); body != want {
+		t.Errorf("unexpected SQL:\ngot  %q\nwant %q", body, want)
+	}
+}
+
+func TestUserSQLRejectsBadTableName(t *testing.T) {
+	calls := 0
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(mockUserJSON))
+	})
+
+	for _, table := range []string{"users;DROP TABLE users", "1users", "users name", "\"users\""} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=sql&table="+url.QueryEscape(table), nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", table, http.StatusBadRequest, rr.Code)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("expected no upstream requests for rejected tables, got %d", calls)
+	}
+}
+
+// mockAvatarUser serves a user whose large picture is pictureURL
+func mockAvatarUser(t *testing.T, pictureURL string) {
+	t.Helper()
+	user := strings.Replace(fmt.Sprintf(mockUserTemplate, "Jane", "Doe", "jane.doe@example.com"),
+		"https://randomuser.me/api/portraits/women/1.jpg", pictureURL, 1)
+	mockUpstream(t, "USER_URL", serveText("application/json", `{"results":[`+user+`]}`))
+}
+
+func TestUserAvatarProxy(t *testing.T) {
+	image := []byte("\x89PNG\r\n\x1a\nfake image bytes")
+	avatar := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(image)
+	})
+	mockAvatarUser(t, avatar.URL+"/portraits/women/1.png")
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?avatar_proxy=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected Content-Type image/png, got %q", ct)
+	}
+	if !bytes.Equal(rr.Body.Bytes(), image) {
+		t.Errorf("expected the proxied image bytes, got %q", rr.Body.Bytes())
+	}
+}
+
+func TestUserAvatarProxyRejectsOversizedImage(t *testing.T) {
+	t.Setenv("AVATAR_MAX_BYTES", "8")
+	avatar := newMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("more than eight bytes"))
+	})
+	mockAvatarUser(t, avatar.URL+"/portraits/women/1.png")
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?avatar_proxy=true", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, rr.Code)
+	}
+}
+
+func TestUserEscapesForwardedParams(t *testing.T) {
+	var upstream *http.Request
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		upstream = r
+		w.Write([]byte(mockUserJSON))
+	})
+
+	query := url.Values{"seed": {"team a&gender=male b"}, "gender": {"female"}}
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+query.Encode(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	if got := upstream.URL.Query()["seed"]; len(got) != 1 || got[0] != "team a&gender=male b" {
+		t.Errorf("expected the seed to reach the upstream intact, got %q", got)
+	}
+	if got := upstream.URL.Query()["gender"]; len(got) != 1 || got[0] != "female" {
+		t.Errorf("expected only the requested gender to be forwarded, got %q", got)
+	}
+	if !strings.Contains(upstream.URL.RawQuery, "seed=team+a%26gender%3Dmale+b") {
+		t.Errorf("expected the seed to be escaped, got raw query %q", upstream.URL.RawQuery)
+	}
+}
+
+func TestUserRejectsUnsafeForwardedParams(t *testing.T) {
+	for _, query := range []string{"seed=abc%0D%0AHost:evil", "seed=a%00b", "gender=other"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestUserUniqueEmail(t *testing.T) {
+	users := []string{
+		fmt.Sprintf(mockUserTemplate, "Jane", "Doe", "jane.doe@example.com"),
+		fmt.Sprintf(mockUserTemplate, "Janet", "Doe", "Jane.Doe@example.com"),
+		fmt.Sprintf(mockUserTemplate, "John", "Doe", "john.doe@example.com"),
+		fmt.Sprintf(mockUserTemplate, "Jane", "Doe", "jane.doe@example.com"),
+	}
+	mockUpstream(t, "USER_URL", serveText("application/json", `{"results":[`+strings.Join(users, ",")+`]}`))
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=4&unique_email=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	want := []string{"jane.doe@example.com", "Jane.Doe+2@example.com", "john.doe@example.com", "jane.doe+3@example.com"}
+	got := decodeUsers(t, rr)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d users, got %d", len(want), len(got))
+	}
+	for i, user := range got {
+		if user["email"] != want[i] {
+			t.Errorf("user %d: expected email %q, got %v", i, want[i], user["email"])
+		}
+	}
+}
+
+func TestUserEmailDomain(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=5&email_domain=MyCorp.test", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	users := decodeUsers(t, rr)
+	if len(users) != 5 {
+		t.Fatalf("expected 5 users, got %d", len(users))
+	}
+	for i, user := range users {
+		email, _ := user["email"].(string)
+		if !strings.HasSuffix(email, "@mycorp.test") || strings.Count(email, "@") != 1 {
+			t.Errorf("user %d: expected an @mycorp.test email, got %q", i, email)
+		}
+	}
+
+	for _, domain := range []string{"my corp.test", "-bad.test", "a..b", "evil.test/path"} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?email_domain="+url.QueryEscape(domain), nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("email_domain=%q: expected status %d, got %d", domain, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestUserHTMLTable(t *testing.T) {
+	users := []string{
+		fmt.Sprintf(mockUserTemplate, "<script>alert(1)</script>", "Doe", "x@example.com"),
+		fmt.Sprintf(mockUserTemplate, "Jane", "Doe", "jane@example.com"),
+		fmt.Sprintf(mockUserTemplate, "John", "Doe", "john@example.com"),
+	}
+	mockUpstream(t, "USER_URL", serveText("application/json", `{"results":[`+strings.Join(users, ",")+`]}`))
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=3&format=html", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %q", ct)
+	}
+
+	body := rr.Body.String()
+	if rows := strings.Count(body, "<tr><td>"); rows != 3 {
+		t.Errorf("expected 3 user rows, got %d:\n%s", rows, body)
+	}
+	if !strings.Contains(body, "<th>Name</th>") {
+		t.Errorf("expected a header row, got:\n%s", body)
+	}
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("expected the name to be escaped, got:\n%s", body)
+	}
+}
+
+func TestUserHTMLTablePages(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=html&seed=pages&page=1&page_size=4&total=10", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	body := rr.Body.String()
+	if rows := strings.Count(body, "<tr><td>"); rows != 4 {
+		t.Errorf("expected 4 user rows, got %d", rows)
+	}
+	if !strings.Contains(body, "page=2") || !strings.Contains(body, "seed=pages") {
+		t.Errorf("expected a link to the next page of the same set, got:\n%s", body)
+	}
+
+	// Transforms that reshape users cannot be rendered
+	rr = httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=html&flatten=true", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("flatten with html: expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestUserUsernameStyle(t *testing.T) {
+	users := []string{
+		fmt.Sprintf(mockUserTemplate, "Jane", "O'Brien", "jane@example.com"),
+		fmt.Sprintf(mockUserTemplate, "Émile", "Van Dijk", "emile@example.com"),
+	}
+	mockUpstream(t, "USER_URL", serveText("application/json", `{"results":[`+strings.Join(users, ",")+`]}`))
+
+	tests := []struct {
+		style string
+		want  []*regexp.Regexp
+	}{
+		{"dot", []*regexp.Regexp{regexp.MustCompile(`^jane\.obrien$`), regexp.MustCompile(`^émile\.vandijk$`)}},
+		{"initial", []*regexp.Regexp{regexp.MustCompile(`^jobrien$`), regexp.MustCompile(`^évandijk$`)}},
+		{"numbered", []*regexp.Regexp{regexp.MustCompile(`^jane[0-9]{1,3}$`), regexp.MustCompile(`^émile[0-9]{1,3}$`)}},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=2&username_style="+tt.style, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v", tt.style, rr.Code, http.StatusOK)
+		}
+		for i, user := range decodeUsers(t, rr) {
+			login, _ := user["login"].(map[string]interface{})
+			username, _ := login["username"].(string)
+			if !tt.want[i].MatchString(username) {
+				t.Errorf("%s: user %d: username %q does not match %s", tt.style, i, username, tt.want[i])
+			}
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?username_style=camel", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("username_style=camel: expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// mockShuffledUserUpstream serves the users with the given first names in a
+// different order on every request
+func mockShuffledUserUpstream(t *testing.T, names ...string) {
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		results := make([]string, len(names))
+		for i, name := range names {
+			results[i] = fmt.Sprintf(mockUserTemplate, name, "Doe", strings.ToLower(name)+"@example.com")
+		}
+		rand.Shuffle(len(results), func(i, j int) { results[i], results[j] = results[j], results[i] })
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results":[` + strings.Join(results, ",") + `]}`))
+	})
+}
+
+func TestUserOrderedIsStable(t *testing.T) {
+	t.Setenv("USER_CACHE_TTL", "0")
+	mockShuffledUserUpstream(t, "Mia", "Ava", "Zoe", "Eli", "Kai", "Ben")
+
+	var golden string
+	for run := 0; run < 5; run++ {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?count=6&seed=golden&ordered=true&with_id=true", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		body := rr.Body.String()
+		if run == 0 {
+			golden = body
+			var firsts []string
+			for _, user := range decodeUsers(t, rr) {
+				firsts = append(firsts, user["name"].(map[string]interface{})["first"].(string))
+			}
+			if want := []string{"Ava", "Ben", "Eli", "Kai", "Mia", "Zoe"}; !reflect.DeepEqual(firsts, want) {
+				t.Errorf("expected users sorted by name %v, got %v", want, firsts)
+			}
+			continue
+		}
+		if body != golden {
+			t.Fatalf("run %d: expected the same body every run:\n%s\n%s", run, golden, body)
+		}
+	}
+}
+
+func TestUserBatchEnvelope(t *testing.T) {
+	mockUserBatchUpstream(t)
+
+	for _, count := range []int{1, 7} {
+		rr := httptest.NewRecorder()
+		handlers.User(rr, httptest.NewRequest("GET", "/random-user?count="+strconv.Itoa(count), nil))
+
+		var batch handlers.UserBatch
+		if err := json.NewDecoder(rr.Body).Decode(&batch); err != nil {
+			t.Fatalf("count=%d: error decoding response: %v", count, err)
+		}
+		if batch.Count != count || len(batch.Results) != count {
+			t.Errorf("count=%d: expected count and results of %d, got count %d with %d results", count, count, batch.Count, len(batch.Results))
+		}
+	}
+}
+
+func TestUserSelectShapesResponse(t *testing.T) {
+	mockUserUpstream(t)
+
+	body := `{"select":{"name":{"first":true,"last":true},"location":{"street":{"name":true}},"email":true}}`
+	rr := httptest.NewRecorder()
+	handlers.UserSelect(rr, httptest.NewRequest("POST", "/random-user/select", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	users := decodeUsers(t, rr)
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	want := map[string]interface{}{
+		"name":     map[string]interface{}{"first": "Jane", "last": "Doe"},
+		"location": map[string]interface{}{"street": map[string]interface{}{"name": "Main Street"}},
+		"email":    "jane.doe@example.com",
+	}
+	if !reflect.DeepEqual(users[0], want) {
+		t.Errorf("expected %v, got %v", want, users[0])
+	}
+}
+
+func TestUserSelectValidatesFields(t *testing.T) {
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{`{"select":{"nickname":true}}`, `unknown field "nickname"`},
+		{`{"select":{"name":{"middle":true}}}`, `unknown field "name.middle"`},
+		{`{"select":{"email":{"domain":true}}}`, `field "email" has no fields to select`},
+		{`{"select":{"email":false}}`, `field "email" must be selected`},
+		{`{"select":{}}`, "select must name at least one field"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handlers.UserSelect(rr, httptest.NewRequest("POST", "/random-user/select", strings.NewReader(tt.body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", tt.body, http.StatusBadRequest, rr.Code)
+			continue
+		}
+		if msg := errorMessage(t, rr); !strings.Contains(msg, tt.message) {
+			t.Errorf("%s: expected message containing %q, got %q", tt.body, tt.message, msg)
+		}
+	}
+}
+
+// mockRepeatingNamesUpstream serves users whose first names cycle through
+// names, so batches repeat names both within and across requests
+func mockRepeatingNamesUpstream(t *testing.T, names ...string) {
+	next := 0
+	mockUpstream(t, "USER_URL", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.URL.Query().Get("results"))
+		if err != nil {
+			n = 1
+		}
+		results := make([]string, n)
+		for i := range results {
+			first := names[next%len(names)]
+			next++
+			results[i] = fmt.Sprintf(mockUserTemplate, first, "Doe", strings.ToLower(first)+"@example.com")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"results":[%s],"info":{"seed":"abc","results":%d,"page":1,"version":"1.4"}}`, strings.Join(results, ","), n)
+	})
+}
+
+// userNames requests /random-user with query and decodes the names
+func userNames(t *testing.T, query string) (int, []string) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?"+query, nil))
+	if rr.Code != http.StatusOK {
+		return rr.Code, nil
+	}
+	var resp handlers.UserNames
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return rr.Code, resp.Names
+}
+
+func TestUserNamesOnlyUnique(t *testing.T) {
+	mockRepeatingNamesUpstream(t, "Ann", "Bob", "ann", "Cat", "Bob", "Dan", "Eve")
+
+	code, names := userNames(t, "names_only=true&unique=true&count=5")
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if len(names) != 5 {
+		t.Fatalf("expected 5 names, got %v", names)
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[strings.ToLower(name)] {
+			t.Errorf("name %q repeated in %v", name, names)
+		}
+		seen[strings.ToLower(name)] = true
+	}
+}
+
+func TestUserNamesOnlyKeepsRepeatsUnlessUnique(t *testing.T) {
+	mockRepeatingNamesUpstream(t, "Ann", "Ann")
+
+	code, names := userNames(t, "names_only=true&count=3")
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if !reflect.DeepEqual(names, []string{"Ann", "Ann", "Ann"}) {
+		t.Errorf("expected three repeated names, got %v", names)
+	}
+}
+
+func TestUserNamesOnlyErrors(t *testing.T) {
+	mockRepeatingNamesUpstream(t, "Ann", "Bob")
+
+	if code, _ := userNames(t, "names_only=true&unique=true&count=3"); code != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, code)
+	}
+	if code, _ := userNames(t, "names_only=true&format=vcard"); code != http.StatusBadRequest {
+		t.Errorf("format=vcard: expected status %d, got %d", http.StatusBadRequest, code)
+	}
+}
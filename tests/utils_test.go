@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+func TestRespondWithErrorHonorsFormat(t *testing.T) {
+	tests := []struct {
+		query       string
+		contentType string
+	}{
+		{"", "application/json"},
+		{"format=json", "application/json"},
+		{"format=ndjson", "application/json"},
+		{"format=text", "text/plain; charset=utf-8"},
+		{"format=apache", "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handlers.RespondWithError(rr, httptest.NewRequest("GET", "/?"+tt.query, nil), "count must be between 1 and 10", http.StatusBadRequest)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", tt.query, http.StatusBadRequest, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("%q: expected Content-Type %q, got %q", tt.query, tt.contentType, ct)
+			continue
+		}
+
+		if tt.contentType == "application/json" {
+			var resp handlers.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Errorf("%q: error decoding error response: %v", tt.query, err)
+			} else if resp.Message != "count must be between 1 and 10" || resp.Code != http.StatusBadRequest {
+				t.Errorf("%q: unexpected error response %+v", tt.query, resp)
+			}
+		} else if body := rr.Body.String(); body != "Bad Request: count must be between 1 and 10\n" {
+			t.Errorf("%q: unexpected text error %q", tt.query, body)
+		}
+	}
+}
+
+func TestHandlerErrorsUseRequestedFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.LogLines(rr, httptest.NewRequest("GET", "/random-logline?format=apache&count=0", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected a plain-text error, got Content-Type %q", ct)
+	}
+	if body := rr.Body.String(); body != "Bad Request: count must be between 1 and 1000\n" {
+		t.Errorf("unexpected error body %q", body)
+	}
+}
+
+func TestMaxCountAppliesToGenerators(t *testing.T) {
+	t.Setenv("MAX_COUNT", "5")
+
+	tests := []struct {
+		handler http.HandlerFunc
+		path    string
+	}{
+		{handlers.UUID, "/random-uuid"},
+		{handlers.LogLines, "/random-logline"},
+		{handlers.Choice, "/random-choice?options=a,b"},
+	}
+	for _, tt := range tests {
+		sep := "?"
+		if strings.Contains(tt.path, "?") {
+			sep = "&"
+		}
+		for count, want := range map[string]int{"5": http.StatusOK, "6": http.StatusBadRequest} {
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest("GET", tt.path+sep+"count="+count, nil))
+			if rr.Code != want {
+				t.Errorf("%s count=%s: expected status %d, got %d: %s", tt.path, count, want, rr.Code, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestPerHandlerCountCapOverridesMaxCount(t *testing.T) {
+	// Passwords keep their own cap of 100 whatever MAX_COUNT is
+	for _, tt := range []struct {
+		maxCount, count string
+		want            int
+	}{
+		{"5", "50", http.StatusOK},
+		{"5000", "101", http.StatusBadRequest},
+	} {
+		t.Setenv("MAX_COUNT", tt.maxCount)
+		rr := httptest.NewRecorder()
+		handlers.Password(rr, httptest.NewRequest("GET", "/random-password?count="+tt.count, nil))
+		if rr.Code != tt.want {
+			t.Errorf("MAX_COUNT=%s count=%s: expected status %d, got %d", tt.maxCount, tt.count, tt.want, rr.Code)
+		}
+	}
+}
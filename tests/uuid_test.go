@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// getUUIDs requests UUIDs and decodes the response
+func getUUIDs(t *testing.T, query string) []string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlers.UUID(rr, httptest.NewRequest("GET", "/random-uuid?"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp handlers.UUIDResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return resp.UUIDs
+}
+
+func TestUUIDVersions(t *testing.T) {
+	for query, version := range map[string]string{"": "4", "version=1": "1", "version=4": "4", "version=7": "7"} {
+		uuids := getUUIDs(t, query+"&count=20")
+		if len(uuids) != 20 {
+			t.Fatalf("%q: expected 20 UUIDs, got %d", query, len(uuids))
+		}
+		seen := map[string]bool{}
+		for _, u := range uuids {
+			m := uuidPattern.FindStringSubmatch(u)
+			if m == nil {
+				t.Errorf("%q: %q is not a valid RFC 4122 UUID", query, u)
+				continue
+			}
+			if m[1] != version {
+				t.Errorf("%q: expected version %s, got %s in %q", query, version, m[1], u)
+			}
+			if seen[u] {
+				t.Errorf("%q: duplicate UUID %q", query, u)
+			}
+			seen[u] = true
+		}
+	}
+}
+
+func TestUUIDv7SortsInGenerationOrder(t *testing.T) {
+	var uuids []string
+	for i := 0; i < 5; i++ {
+		uuids = append(uuids, getUUIDs(t, "version=7&count=1000")...)
+	}
+	if !sort.StringsAreSorted(uuids) {
+		t.Errorf("expected version 7 UUIDs to sort in generation order")
+	}
+}
+
+func TestUUIDRejectsUnsupportedVersion(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.UUID(rr, httptest.NewRequest("GET", "/random-uuid?version=3", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// xmlElement decodes any element and its descendants
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Text     string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+// stats returns the number of descendants of e and how deeply they nest
+func (e xmlElement) stats() (count, depth int) {
+	for _, c := range e.Children {
+		n, d := c.stats()
+		count += n + 1
+		if d+1 > depth {
+			depth = d + 1
+		}
+	}
+	return count, depth
+}
+
+func TestXMLIsWellFormed(t *testing.T) {
+	for _, q := range []struct{ depth, elements int }{{1, 5}, {3, 10}, {10, 200}} {
+		rr := httptest.NewRecorder()
+		url := "/random-xml?depth=" + strconv.Itoa(q.depth) + "&elements=" + strconv.Itoa(q.elements)
+		handlers.XML(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v", url, rr.Code, http.StatusOK)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+			t.Errorf("%s: expected application/xml, got %q", url, ct)
+		}
+
+		var root xmlElement
+		if err := xml.Unmarshal(rr.Body.Bytes(), &root); err != nil {
+			t.Fatalf("%s: output is not well-formed XML: %v\n%s", url, err, rr.Body.String())
+		}
+		count, depth := root.stats()
+		if count != q.elements {
+			t.Errorf("%s: expected %d elements under the root, got %d", url, q.elements, count)
+		}
+		if depth > q.depth {
+			t.Errorf("%s: expected depth at most %d, got %d", url, q.depth, depth)
+		}
+	}
+}
+
+func TestXMLRejectsOutOfRangeParams(t *testing.T) {
+	for _, query := range []string{"depth=0", "depth=11", "elements=0", "elements=1001", "depth=deep"} {
+		rr := httptest.NewRecorder()
+		handlers.XML(rr, httptest.NewRequest("GET", "/random-xml?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
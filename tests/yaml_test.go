@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/github/testdatabot/handlers"
+)
+
+// yamlLine is one line of a block-style YAML document
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+var yamlKeyPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*|"(?:[^"\\]|\\.)*"):(?: (.*))?$`)
+
+// parseYAML decodes the block-style YAML subset produced by the API, whose
+// scalars are all JSON literals, into the same values encoding/json would
+func parseYAML(t *testing.T, doc string) interface{} {
+	t.Helper()
+	if !strings.HasPrefix(doc, "---\n") {
+		t.Fatalf("expected a YAML document start marker, got %q", doc)
+	}
+
+	var lines []yamlLine
+	for _, l := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(doc, "---\n"), "\n"), "\n") {
+		trimmed := strings.TrimLeft(l, " ")
+		lines = append(lines, yamlLine{indent: len(l) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 1 && !yamlKeyPattern.MatchString(lines[0].text) && !strings.HasPrefix(lines[0].text, "- ") {
+		return parseYAMLScalar(t, lines[0].text)
+	}
+	v, rest := parseYAMLBlock(t, lines)
+	if len(rest) != 0 {
+		t.Fatalf("unexpected trailing YAML: %+v", rest)
+	}
+	return v
+}
+
+// parseYAMLBlock parses the map or list starting at lines[0], returning it
+// with the lines that follow it
+func parseYAMLBlock(t *testing.T, lines []yamlLine) (interface{}, []yamlLine) {
+	t.Helper()
+	indent := lines[0].indent
+
+	if strings.HasPrefix(lines[0].text, "- ") {
+		list := []interface{}{}
+		for len(lines) > 0 && lines[0].indent == indent && strings.HasPrefix(lines[0].text, "- ") {
+			item := strings.TrimPrefix(lines[0].text, "- ")
+			if !yamlKeyPattern.MatchString(item) && !strings.HasPrefix(item, "- ") {
+				list = append(list, parseYAMLScalar(t, item))
+				lines = lines[1:]
+				continue
+			}
+			// A nested block starts on the dash line, two columns further in
+			lines[0] = yamlLine{indent: indent + 2, text: item}
+			var v interface{}
+			v, lines = parseYAMLBlock(t, lines)
+			list = append(list, v)
+		}
+		return list, lines
+	}
+
+	m := map[string]interface{}{}
+	for len(lines) > 0 && lines[0].indent == indent {
+		match := yamlKeyPattern.FindStringSubmatch(lines[0].text)
+		if match == nil {
+			t.Fatalf("invalid YAML mapping line %q", lines[0].text)
+		}
+		key := match[1]
+		if strings.HasPrefix(key, `"`) {
+			key = parseYAMLScalar(t, key).(string)
+		}
+		lines = lines[1:]
+		if match[2] != "" {
+			m[key] = parseYAMLScalar(t, match[2])
+			continue
+		}
+		if len(lines) == 0 || lines[0].indent <= indent {
+			t.Fatalf("missing block value for key %q", key)
+		}
+		m[key], lines = parseYAMLBlock(t, lines)
+	}
+	return m, lines
+}
+
+// parseYAMLScalar decodes a flow scalar written as a JSON literal
+func parseYAMLScalar(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid YAML scalar %q: %v", s, err)
+	}
+	return v
+}
+
+// jsonValue decodes data as generic JSON
+func jsonValue(t *testing.T, data string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("error decoding JSON: %v", err)
+	}
+	return v
+}
+
+func TestRespondWithYAMLRoundTrips(t *testing.T) {
+	data := `{
+		"name": "O'Brien: \"quoted\"\nsecond line",
+		"unicode": "Siobhán ✓",
+		"count": 3,
+		"ratio": 0.25,
+		"big": 1e+21,
+		"active": true,
+		"missing": null,
+		"empty_map": {},
+		"empty_list": [],
+		"key with spaces": "yes",
+		"nested": {"list": [1, "two", {"three": 3, "deeper": [[], [4]]}, []]},
+		"users": [{"first": "Jane", "tags": ["a", "b"]}, {"first": "John"}]
+	}`
+
+	rr := httptest.NewRecorder()
+	handlers.RespondWithYAML(rr, json.RawMessage(data), http.StatusOK)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-yaml" {
+		t.Errorf("expected Content-Type application/x-yaml, got %q", ct)
+	}
+	if got, want := parseYAML(t, rr.Body.String()), jsonValue(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("YAML did not round-trip:\n%s\ngot  %#v\nwant %#v", rr.Body.String(), got, want)
+	}
+}
+
+func TestRespondWithYAMLQuotesEdgeCases(t *testing.T) {
+	data := `{
+		"null": 1, "True": 2, "yes": 3, "OFF": 4, "n": 5, "~": 6,
+		"-dash": 7, "a: b": 8, "#hash": 9, "nullable": 10,
+		"values": ["a: b", "- item", "null", "yes", "#comment", "~"]
+	}`
+	want := `---
+"null": 1
+"True": 2
+"yes": 3
+"OFF": 4
+"n": 5
+"~": 6
+"-dash": 7
+"a: b": 8
+"#hash": 9
+nullable: 10
+values:
+  - "a: b"
+  - "- item"
+  - "null"
+  - "yes"
+  - "#comment"
+  - "~"
+`
+
+	rr := httptest.NewRecorder()
+	handlers.RespondWithYAML(rr, json.RawMessage(data), http.StatusOK)
+	if got := rr.Body.String(); got != want {
+		t.Errorf("unexpected YAML:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUserYAMLFormat(t *testing.T) {
+	mockUserUpstream(t)
+
+	rr := httptest.NewRecorder()
+	handlers.User(rr, httptest.NewRequest("GET", "/random-user?format=yaml", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-yaml" {
+		t.Errorf("expected Content-Type application/x-yaml, got %q", ct)
+	}
+	want := jsonValue(t, mockUserJSON).(map[string]interface{})
+	want["count"] = float64(1)
+	if got := parseYAML(t, rr.Body.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("user YAML does not match the upstream JSON:\n%s", rr.Body.String())
+	}
+}
+
+func TestHealthYAMLFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.Health(rr, httptest.NewRequest("GET", "/health?format=yaml", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-yaml" {
+		t.Errorf("expected Content-Type application/x-yaml, got %q", ct)
+	}
+
+	health, ok := parseYAML(t, rr.Body.String()).(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected a YAML mapping, got %q", rr.Body.String())
+	}
+	if health["status"] != "ok" {
+		t.Errorf("expected status ok, got %v", health["status"])
+	}
+	if _, ok := health["memory"].(map[string]interface{}); !ok {
+		t.Errorf("expected a nested memory mapping, got %v", health["memory"])
+	}
+}